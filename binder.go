@@ -5,7 +5,6 @@
 package revel
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -303,10 +302,10 @@ func unbindSlice(output map[string]string, name string, val interface{}) {
 func bindStruct(params *Params, name string, typ reflect.Type) reflect.Value {
 	resultPointer := reflect.New(typ)
 	result := resultPointer.Elem()
-	if params.JSON != nil {
-		// Try to inject the response as a json into the created result
-		if err := json.Unmarshal(params.JSON, resultPointer.Interface()); err != nil {
-			binderLog.Error("bindStruct Unable to unmarshal request", "name", name, "error", err, "data", string(params.JSON))
+	if body, unmarshal := params.bodyUnmarshaler(); unmarshal != nil {
+		// Try to inject the response as a json (or decoded body) into the created result
+		if err := unmarshal(body, resultPointer.Interface()); err != nil {
+			binderLog.Error("bindStruct Unable to unmarshal request", "name", name, "error", err, "data", string(body))
 		}
 		return result
 	}
@@ -432,9 +431,9 @@ func bindMap(params *Params, name string, typ reflect.Type) reflect.Value {
 		result    = resultPtr.Elem()
 	)
 	result.Set(reflect.MakeMap(typ))
-	if params.JSON != nil {
-		// Try to inject the response as a json into the created result
-		if err := json.Unmarshal(params.JSON, resultPtr.Interface()); err != nil {
+	if body, unmarshal := params.bodyUnmarshaler(); unmarshal != nil {
+		// Try to inject the response as a json (or decoded body) into the created result
+		if err := unmarshal(body, resultPtr.Interface()); err != nil {
 			binderLog.Warn("bindMap: Unable to unmarshal request", "name", name, "error", err)
 		}
 		return result
@@ -15,6 +15,15 @@ var cacheLog = revel.RevelLog.New("section", "cache")
 
 func init() {
 	revel.OnAppStart(func() {
+		// Fan the websocket broadcasts out through Redis?
+		if revel.Config.StringDefault("websocket.pubsub", "local") == "redis" {
+			hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
+			if len(hosts[0]) == 0 {
+				cacheLog.Panic("Redis pubsub enabled but no Redis hosts specified!")
+			}
+			revel.DefaultPubSub = NewRedisPubSub(hosts[0], revel.Config.StringDefault("cache.redis.password", ""))
+		}
+
 		// Set the default expiration time.
 		defaultExpiration := time.Hour // The default for the default is one hour.
 		if expireStr, found := revel.Config.String("cache.expires"); found {
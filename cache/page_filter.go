@@ -0,0 +1,343 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// The filter option holding the PageCache settings of an action.
+const PageCacheOption = "cache.page"
+
+// The Controller.Args key holding the tags added by AddPageTags.
+const pageTagsArg = "cache.page.tags"
+
+// PageCache holds the settings of the CachePageFilter for an action. For
+// example:
+//
+//	revel.FilterAction(Hotels.Show).
+//	  Add(cache.CachePageFilter).
+//	  SetOption(cache.PageCacheOption, cache.PageCache{
+//	    TTL:   time.Minute,
+//	    Stale: 10 * time.Minute,
+//	    Vary:  []string{"Accept-Language"},
+//	    Tags:  []string{"hotels"},
+//	  })
+type PageCache struct {
+	TTL     time.Duration // The time the page is fresh, cache.page.ttl (default 1m) if zero
+	Stale   time.Duration // The time a stale page is served while it is rendered again, cache.page.stale if zero
+	Vary    []string      // The request headers selecting between versions of the page
+	Tags    []string      // The tags of the page, see InvalidatePageTags
+	Private bool          // Send Cache-Control: private and do not store the page, for pages which differ per user
+}
+
+// CachedPage is a response stored by the CachePageFilter.
+type CachedPage struct {
+	Status int                 // The status
+	Header map[string][]string // The headers, excluding cookies
+	Body   []byte              // The body
+	Stored time.Time           // The time the page was rendered
+	Fresh  time.Time           // The time the page becomes stale
+	Tags   map[string]int64    // The versions of the tags when the page was rendered
+}
+
+// CachePageFilter caches the responses of the GET and HEAD requests for an
+// action, the rendered page is served from the cache until the TTL passes.
+// The cache key is made of the method, host, path, query and the Vary request
+// headers of the PageCache. Once the page is stale one request renders it
+// again, while the other requests are served the stale page until the
+// Stale time has passed as well. The page is removed when one of its tags is
+// invalidated.
+//
+// A response which sets no Cache-Control or ETag header is given a
+// Cache-Control matching the settings and an ETag from the body, requests
+// with a matching If-None-Match are answered with a 304. Only 200 responses
+// are cached, the Private pages and the responses with Cache-Control no-store
+// or private are not.
+func CachePageFilter(c *revel.Controller, fc []revel.Filter) {
+	if c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+		fc[0](c, fc[1:])
+		return
+	}
+	settings := pageCacheSettings(c)
+	key := pageKey(c.Request, settings.Vary)
+
+	var page CachedPage
+	locked := false
+	if err := Get(key, &page); err == nil && page.current() {
+		// When stale, the request which gets the lock renders the page again
+		if time.Now().Before(page.Fresh) || Add(key+":lock", int64(1), pageLockTime()) != nil {
+			c.Result = &cachedPageResult{page: page}
+			return
+		}
+		locked = true
+	}
+
+	// Buffer the headers and status, so they can be stored with the body
+	header := revel.NewBufferedServerHeader(c.Response.Out.Header().Server)
+	c.Response.Out.Header().Server = header
+	fc[0](c, fc[1:])
+	if c.Result == nil {
+		header.Release()
+		return
+	}
+	tags := append([]string{}, settings.Tags...)
+	if added, ok := c.Args[pageTagsArg].([]string); ok {
+		tags = append(tags, added...)
+	}
+	c.Result = &pageRecordingResult{
+		Result:   c.Result,
+		header:   header,
+		key:      key,
+		settings: settings,
+		tags:     tags,
+		locked:   locked,
+	}
+}
+
+// AddPageTags adds tags to the page rendered by the action, in addition to
+// the tags of the PageCache. For example:
+//
+//	cache.AddPageTags(c.Controller, fmt.Sprintf("hotel:%d", id))
+func AddPageTags(c *revel.Controller, tags ...string) {
+	existing, _ := c.Args[pageTagsArg].([]string)
+	c.Args[pageTagsArg] = append(existing, tags...)
+}
+
+// InvalidatePageTags removes the pages with any of the tags from the cache.
+func InvalidatePageTags(tags ...string) (err error) {
+	for _, tag := range tags {
+		key := pageTagKey(tag)
+		if _, err = Increment(key, 1); err == ErrCacheMiss {
+			err = Set(key, int64(1), ForEverNeverExpiry)
+		}
+		if err != nil {
+			cacheLog.Error("InvalidatePageTags: Failed to invalidate tag", "tag", tag, "error", err)
+			return
+		}
+	}
+	return
+}
+
+// The result storing the page rendered by the action.
+type pageRecordingResult struct {
+	revel.Result
+	header   *revel.BufferedServerHeader
+	key      string
+	settings PageCache
+	tags     []string
+	locked   bool // True if this request holds the lock to render the stale page
+}
+
+// Apply renders the page into a buffer, then stores and writes it.
+func (r *pageRecordingResult) Apply(req *revel.Request, resp *revel.Response) {
+	if r.locked {
+		defer func() {
+			_ = Delete(r.key + ":lock")
+		}()
+	}
+
+	writer := resp.GetWriter()
+	recorder := &pageRecorder{writer: writer, header: r.header, limit: revel.Config.IntDefault("cache.page.maxsize", 1<<20)}
+	resp.SetWriter(recorder)
+	r.Result.Apply(req, resp)
+	resp.SetWriter(writer)
+	if recorder.overflow {
+		// Too large to cache, the body has been passed on
+		return
+	}
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	page := CachedPage{
+		Status: status,
+		Header: map[string][]string{},
+		Body:   recorder.buffer.Bytes(),
+		Stored: time.Now(),
+		Fresh:  time.Now().Add(r.settings.TTL),
+		Tags:   tagVersions(r.tags),
+	}
+	cacheControl := r.header.Get("Cache-Control")
+	if status == http.StatusOK && !pageCacheControlForbids(cacheControl) {
+		if len(cacheControl) == 0 {
+			visibility := "public"
+			if r.settings.Private {
+				visibility = "private"
+			}
+			r.header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d",
+				visibility, int(r.settings.TTL.Seconds()), int(r.settings.Stale.Seconds())))
+		}
+		if len(r.header.Get("ETag")) == 0 {
+			sum := sha1.Sum(page.Body)
+			r.header.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		}
+		for _, name := range r.settings.Vary {
+			r.header.Add("Vary", name)
+		}
+		// The key does not tell the users apart, so a private page is not
+		// stored, only its ETag is checked
+		if !r.settings.Private && !pageCacheControlForbids(r.header.Get("Cache-Control")) {
+			for _, name := range r.header.GetKeys() {
+				if !strings.EqualFold(name, "Set-Cookie") {
+					page.Header[name] = r.header.Get(name)
+				}
+			}
+			if err := Set(r.key, page, r.settings.TTL+r.settings.Stale); err != nil {
+				cacheLog.Error("CachePageFilter: Failed to store page", "path", req.GetPath(), "error", err)
+			}
+		}
+		if revel.ETagListMatches(req.GetHttpHeader("If-None-Match"), r.header.Get("ETag")[0], false) {
+			r.header.Del("Content-Length")
+			r.header.SetStatus(http.StatusNotModified)
+			r.header.Release()
+			return
+		}
+	}
+
+	r.header.Release()
+	if _, err := writer.Write(page.Body); err != nil {
+		cacheLog.Error("CachePageFilter: Response write failed", "error", err)
+	}
+}
+
+// The result writing a page from the cache.
+type cachedPageResult struct {
+	page CachedPage
+}
+
+// Apply writes the cached headers and body.
+func (r *cachedPageResult) Apply(req *revel.Request, resp *revel.Response) {
+	header := resp.Out.Header().Server
+	for name, values := range r.page.Header {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(r.page.Stored).Seconds())))
+
+	status := r.page.Status
+	if etag := r.page.Header["ETag"]; len(etag) > 0 && revel.ETagListMatches(req.GetHttpHeader("If-None-Match"), etag[0], false) {
+		header.Del("Content-Length")
+		status = http.StatusNotModified
+	}
+	resp.Status = status
+	resp.SetStatus(status)
+	if status == http.StatusNotModified {
+		return
+	}
+	if _, err := resp.GetWriter().Write(r.page.Body); err != nil {
+		cacheLog.Error("CachePageFilter: Response write failed", "error", err)
+	}
+}
+
+// Returns true if none of the tags of the page have been invalidated since it
+// was stored.
+func (page *CachedPage) current() bool {
+	for tag, version := range page.Tags {
+		if tagVersions([]string{tag})[tag] != version {
+			return false
+		}
+	}
+	return true
+}
+
+// Buffers the page, once it exceeds the limit the headers are released and
+// the page is passed on to the writer.
+type pageRecorder struct {
+	writer   io.Writer
+	header   *revel.BufferedServerHeader
+	buffer   bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// Write buffers the content.
+func (r *pageRecorder) Write(b []byte) (int, error) {
+	if r.overflow {
+		return r.writer.Write(b)
+	}
+	if r.buffer.Len()+len(b) <= r.limit {
+		return r.buffer.Write(b)
+	}
+	r.overflow = true
+	r.header.Release()
+	if _, err := r.writer.Write(r.buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	return r.writer.Write(b)
+}
+
+// Returns the settings of the action, with the defaults filled in.
+func pageCacheSettings(c *revel.Controller) (settings PageCache) {
+	if value, found := c.FilterOption(PageCacheOption); found {
+		settings, _ = value.(PageCache)
+	}
+	if settings.TTL <= 0 {
+		settings.TTL = revel.ConfigDuration("cache.page.ttl", time.Minute)
+	}
+	if settings.Stale <= 0 {
+		settings.Stale = revel.ConfigDuration("cache.page.stale", 0)
+	}
+	return
+}
+
+// Returns the time a request has to render a stale page again, before
+// another request may do so.
+func pageLockTime() time.Duration {
+	return revel.ConfigDuration("cache.page.lock", 30*time.Second)
+}
+
+// Returns the cache key of the page for the request.
+func pageKey(req *revel.Request, vary []string) string {
+	hash := sha1.New()
+	// The encoded query is sorted by name
+	fmt.Fprintf(hash, "%s %s%s?%s", req.Method, req.Host, req.GetPath(), req.GetQuery().Encode())
+	for _, name := range vary {
+		fmt.Fprintf(hash, "\n%s: %s", strings.ToLower(name), req.GetHttpHeader(name))
+	}
+	return "revel/page:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the cache key of the version of the tag.
+func pageTagKey(tag string) string {
+	return "revel/page/tag:" + tag
+}
+
+// Returns the current versions of the tags, zero if not invalidated yet.
+func tagVersions(tags []string) map[string]int64 {
+	versions := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		var version int64
+		if err := Get(pageTagKey(tag), &version); err != nil && err != ErrCacheMiss {
+			cacheLog.Error("CachePageFilter: Failed to get tag version", "tag", tag, "error", err)
+		}
+		versions[tag] = version
+	}
+	return versions
+}
+
+// Returns true if the Cache-Control of the response forbids caching it.
+func pageCacheControlForbids(cacheControl []string) bool {
+	for _, value := range cacheControl {
+		value = strings.ToLower(value)
+		if strings.Contains(value, "no-store") || strings.Contains(value, "private") {
+			return true
+		}
+	}
+	return false
+}
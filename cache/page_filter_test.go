@@ -0,0 +1,192 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revel/config"
+	"github.com/revel/revel"
+)
+
+// The controller of the pages whose PageCache is set by the tests.
+type pageController struct {
+	*revel.Controller
+}
+
+// Requests the page through the CachePageFilter, the renders counts the times
+// the action was invoked.
+func requestPage(headers map[string]string, renders *int) *httptest.ResponseRecorder {
+	return requestControllerPage("", headers, renders)
+}
+
+// Requests the page of the controller through the CachePageFilter.
+func requestControllerPage(name string, headers map[string]string, renders *int) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "http://localhost/hotels?b=2&a=1", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(req)
+	context.Response.SetResponse(resp)
+	c := revel.NewController(context)
+	c.Name = name
+
+	CachePageFilter(c, []revel.Filter{func(c *revel.Controller, _ []revel.Filter) {
+		*renders++
+		AddPageTags(c, "hotels")
+		c.Result = c.RenderText("Hotels in " + c.Request.GetHttpHeader("Accept-Language"))
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	return resp
+}
+
+// Returns the revel request for the url.
+func newPageRequest(url string, headers map[string]string) *revel.Request {
+	req, _ := http.NewRequest("GET", url, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(req)
+	request := revel.NewRequest(nil)
+	request.SetRequest(context.Request)
+	return request
+}
+
+func TestCachePageFilter(t *testing.T) {
+	revel.Config = config.NewContext()
+	Instance = NewInMemoryCache(time.Hour)
+	renders := 0
+
+	resp := requestPage(nil, &renders)
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || resp.Body.String() != "Hotels in " || etag == "" {
+		t.Errorf("Unexpected response %d %q %q", resp.Code, resp.Body.String(), etag)
+	}
+	if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "public, max-age=60, stale-while-revalidate=0" {
+		t.Errorf("Unexpected Cache-Control %q", cacheControl)
+	}
+
+	resp = requestPage(nil, &renders)
+	if renders != 1 || resp.Body.String() != "Hotels in " || resp.Header().Get("Age") == "" || resp.Header().Get("ETag") != etag {
+		t.Errorf("Expected the cached page, rendered %d times", renders)
+	}
+	if resp.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("Expected the cached headers, got %v", resp.Header())
+	}
+
+	resp = requestPage(map[string]string{"If-None-Match": etag}, &renders)
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Errorf("Expected a 304 for a matching ETag, got %d", resp.Code)
+	}
+
+	if err := InvalidatePageTags("hotels"); err != nil {
+		t.Fatalf("InvalidatePageTags failed: %s", err)
+	}
+	requestPage(nil, &renders)
+	requestPage(nil, &renders)
+	if renders != 2 {
+		t.Errorf("Expected the page to be rendered again once after the tag was invalidated, rendered %d times", renders)
+	}
+}
+
+func TestCachePageFilterVary(t *testing.T) {
+	revel.Config = config.NewContext()
+	Instance = NewInMemoryCache(time.Hour)
+	renders := 0
+
+	// Without the header in the Vary settings, the first page is served for both
+	requestPage(map[string]string{"Accept-Language": "en"}, &renders)
+	if resp := requestPage(map[string]string{"Accept-Language": "fr"}, &renders); resp.Body.String() != "Hotels in en" {
+		t.Errorf("Expected the cached page, got %q", resp.Body.String())
+	}
+
+	// The query is keyed regardless of the order of the parameters
+	en := newPageRequest("http://localhost/hotels?a=1&b=2", map[string]string{"Accept-Language": "en"})
+	fr := newPageRequest("http://localhost/hotels?b=2&a=1", map[string]string{"Accept-Language": "fr"})
+	if pageKey(en, nil) != pageKey(fr, nil) {
+		t.Error("Expected the same key without Vary headers")
+	}
+	if pageKey(en, []string{"Accept-Language"}) == pageKey(fr, []string{"Accept-Language"}) {
+		t.Error("Expected a key per Vary header value")
+	}
+}
+
+func TestCachePageFilterVaryHeaders(t *testing.T) {
+	revel.Config = config.NewContext()
+	Instance = NewInMemoryCache(time.Hour)
+	revel.FilterController(pageController{}).SetOption(PageCacheOption, PageCache{Vary: []string{"Accept-Language", "Cookie"}})
+	defer revel.FilterController(pageController{}).SetOption(PageCacheOption, PageCache{})
+	renders := 0
+
+	// Every name of the key is sent, on the rendered and the cached page
+	for i := 0; i < 2; i++ {
+		resp := requestControllerPage("pageController", map[string]string{"Accept-Language": "en"}, &renders)
+		if vary := strings.Join(resp.Header().Values("Vary"), ", "); vary != "Accept-Language, Cookie" {
+			t.Errorf("Expected both Vary names, got %q", vary)
+		}
+	}
+	if renders != 1 {
+		t.Errorf("Expected the cached page, rendered %d times", renders)
+	}
+}
+
+func TestCachePageFilterStale(t *testing.T) {
+	revel.Config = config.NewContext()
+	revel.Config.SetOption("cache.page.ttl", "1ms")
+	revel.Config.SetOption("cache.page.stale", "1h")
+	Instance = NewInMemoryCache(time.Hour)
+	renders := 0
+
+	requestPage(nil, &renders)
+	time.Sleep(5 * time.Millisecond)
+
+	// Another request is rendering the stale page
+	key := pageKey(newPageRequest("http://localhost/hotels?b=2&a=1", nil), nil)
+	if err := Add(key+":lock", int64(1), time.Minute); err != nil {
+		t.Fatalf("Failed to lock the page: %s", err)
+	}
+	requestPage(nil, &renders)
+	if renders != 1 {
+		t.Errorf("Expected the stale page while it is rendered, rendered %d times", renders)
+	}
+
+	_ = Delete(key + ":lock")
+	requestPage(nil, &renders)
+	if renders != 2 {
+		t.Errorf("Expected the stale page to be rendered again, rendered %d times", renders)
+	}
+}
+
+func TestCachePageFilterPrivate(t *testing.T) {
+	revel.Config = config.NewContext()
+	Instance = NewInMemoryCache(time.Hour)
+	revel.FilterController(pageController{}).SetOption(PageCacheOption, PageCache{Private: true})
+	defer revel.FilterController(pageController{}).SetOption(PageCacheOption, PageCache{})
+	renders := 0
+
+	resp := requestControllerPage("pageController", map[string]string{"Accept-Language": "en"}, &renders)
+	if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "private, max-age=60, stale-while-revalidate=0" {
+		t.Errorf("Unexpected Cache-Control %q", cacheControl)
+	}
+	etag := resp.Header().Get("ETag")
+
+	// Another user is never served the page of the first one
+	resp = requestControllerPage("pageController", map[string]string{"Accept-Language": "fr"}, &renders)
+	if renders != 2 || resp.Body.String() != "Hotels in fr" || resp.Header().Get("Age") != "" {
+		t.Errorf("Expected the private page to be rendered again, rendered %d times, got %q", renders, resp.Body.String())
+	}
+
+	resp = requestControllerPage("pageController", map[string]string{"Accept-Language": "en", "If-None-Match": etag}, &renders)
+	if resp.Code != http.StatusNotModified {
+		t.Errorf("Expected a 304 for a matching ETag, got %d", resp.Code)
+	}
+}
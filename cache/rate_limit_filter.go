@@ -0,0 +1,175 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+// The filter option holding the RateLimit settings of an action.
+const RateLimitOption = "cache.ratelimit"
+
+// RateLimit holds the settings of the RateLimitFilter for an action. For
+// example, to allow 10 logins a minute per client:
+//
+//	revel.FilterAction(App.Login).
+//	  Add(cache.RateLimitFilter).
+//	  SetOption(cache.RateLimitOption, cache.RateLimit{
+//	    Limit:  10,
+//	    Window: time.Minute,
+//	  })
+type RateLimit struct {
+	Limit  int                              // The requests allowed per window, cache.ratelimit.limit if zero
+	Window time.Duration                    // The length of the window, cache.ratelimit.window (default 1m) if zero
+	Key    func(c *revel.Controller) string // Returns the client the request is counted for, cache.ratelimit.key if nil
+	Scope  string                           // The actions sharing the same scope share the counters, the action if empty
+}
+
+// RateLimitByIP counts the requests per client IP.
+func RateLimitByIP(c *revel.Controller) string {
+	return "ip:" + c.ClientIP
+}
+
+// RateLimitBySession counts the requests per session, the SessionFilter must
+// run before the RateLimitFilter. A client choosing whether to send its
+// session cookie, the requests without a session id are counted per client
+// IP. The session is given an id for the next requests.
+func RateLimitBySession(c *revel.Controller) string {
+	if c.Session == nil {
+		return RateLimitByIP(c)
+	}
+	if id, ok := c.Session[session.SessionIDKey].(string); ok && id != "" {
+		return "session:" + id
+	}
+	c.Session.ID()
+	return RateLimitByIP(c)
+}
+
+// RateLimitByHeader returns a key counting the requests per value of the
+// header, e.g. an API key. The requests without the header are counted per
+// client IP.
+//
+// A client is free to send a new value with each request, so only use a
+// header checked by a filter running before the RateLimitFilter, such as an
+// API key rejected when it is unknown.
+func RateLimitByHeader(name string) func(c *revel.Controller) string {
+	return func(c *revel.Controller) string {
+		if value := c.Request.GetHttpHeader(name); value != "" {
+			// The value is hashed, so the cache holds no API keys
+			sum := sha1.Sum([]byte(value))
+			return "header:" + hex.EncodeToString(sum[:])
+		}
+		return RateLimitByIP(c)
+	}
+}
+
+// RateLimitFilter limits the number of requests a client may make to the
+// action in a sliding window, the counters are kept in the cache so the limit
+// applies across the instances sharing it. The responses carry the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and once
+// the limit is reached the requests are answered with a 429 and Retry-After.
+//
+// The settings are read from the RateLimit option of the action, or from
+// app.conf: cache.ratelimit.limit (0 disables the filter),
+// cache.ratelimit.window and cache.ratelimit.key (ip, session or
+// header:<name>, default ip). See RateLimitBySession and RateLimitByHeader
+// for the requests they count per client IP, and the headers which may be
+// used.
+func RateLimitFilter(c *revel.Controller, fc []revel.Filter) {
+	settings := rateLimitSettings(c)
+	if settings.Limit <= 0 {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	now := time.Now()
+	window := now.UnixNano() / int64(settings.Window)
+	key := "revel/ratelimit:" + settings.Scope + ":" + settings.Key(c) + ":"
+	current, err := rateLimitIncrement(key+strconv.FormatInt(window, 10), 2*settings.Window)
+	if err != nil {
+		// Do not lock the clients out when the cache fails
+		cacheLog.Error("RateLimitFilter: Failed to count request", "error", err)
+		fc[0](c, fc[1:])
+		return
+	}
+	var previous int64
+	if err = Get(key+strconv.FormatInt(window-1, 10), &previous); err != nil && err != ErrCacheMiss {
+		cacheLog.Error("RateLimitFilter: Failed to get previous count", "error", err)
+	}
+
+	// The previous window is weighted by the part of it still in the sliding window
+	elapsed := time.Duration(now.UnixNano() % int64(settings.Window))
+	weight := 1 - float64(elapsed)/float64(settings.Window)
+	count := int(float64(previous)*weight) + int(current)
+	reset := int((settings.Window - elapsed + time.Second - 1) / time.Second)
+
+	header := c.Response.Out.Header().Server
+	header.Set("RateLimit-Limit", strconv.Itoa(settings.Limit))
+	header.Set("RateLimit-Reset", strconv.Itoa(reset))
+	if count > settings.Limit {
+		header.Set("RateLimit-Remaining", "0")
+		header.Set("Retry-After", strconv.Itoa(reset))
+		cacheLog.Warn("RateLimitFilter: Limit exceeded", "action", c.Action, "scope", settings.Scope, "limit", settings.Limit)
+		c.Response.Status = http.StatusTooManyRequests
+		c.Result = c.RenderError(&revel.Error{
+			Title:       http.StatusText(http.StatusTooManyRequests),
+			Description: fmt.Sprintf("The limit of %d requests per %s has been reached", settings.Limit, settings.Window),
+		})
+		return
+	}
+	header.Set("RateLimit-Remaining", strconv.Itoa(settings.Limit-count))
+	fc[0](c, fc[1:])
+}
+
+// Returns the settings of the action, with the defaults filled in.
+func rateLimitSettings(c *revel.Controller) (settings RateLimit) {
+	if value, found := c.FilterOption(RateLimitOption); found {
+		settings, _ = value.(RateLimit)
+	}
+	if settings.Limit == 0 {
+		settings.Limit = revel.Config.IntDefault("cache.ratelimit.limit", 0)
+	}
+	if settings.Window <= 0 {
+		settings.Window = revel.ConfigDuration("cache.ratelimit.window", time.Minute)
+	}
+	if settings.Key == nil {
+		switch key := revel.Config.StringDefault("cache.ratelimit.key", "ip"); {
+		case key == "session":
+			settings.Key = RateLimitBySession
+		case strings.HasPrefix(key, "header:"):
+			settings.Key = RateLimitByHeader(strings.TrimPrefix(key, "header:"))
+		default:
+			settings.Key = RateLimitByIP
+		}
+	}
+	if settings.Scope == "" {
+		settings.Scope = c.Action
+	}
+	return
+}
+
+// Increments the counter, creating it if needed.
+func rateLimitIncrement(key string, expires time.Duration) (uint64, error) {
+	count, err := Increment(key, 1)
+	if err != ErrCacheMiss {
+		return count, err
+	}
+	if err = Add(key, int64(1), expires); err == nil {
+		return 1, nil
+	} else if err != ErrNotStored {
+		return 0, err
+	}
+	// Another request created the counter meanwhile
+	return Increment(key, 1)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/revel/config"
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+// Requests the action through the RateLimitFilter, returning the response and
+// true if the action was invoked.
+func requestRateLimited(clientIP, apiKey string) (*httptest.ResponseRecorder, bool) {
+	req, _ := http.NewRequest("GET", "http://localhost/api/hotels", nil)
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	resp := httptest.NewRecorder()
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(req)
+	context.Response.SetResponse(resp)
+	c := revel.NewController(context)
+	c.ClientIP = clientIP
+	c.Action = "Api.Hotels"
+
+	invoked := false
+	RateLimitFilter(c, []revel.Filter{func(c *revel.Controller, _ []revel.Filter) {
+		invoked = true
+		c.Result = c.RenderText("ok")
+	}})
+	if !invoked {
+		// The error result needs the templates, only the status and headers are checked
+		c.Response.SetStatus(c.Response.Status)
+	} else {
+		c.Result.Apply(c.Request, c.Response)
+	}
+	return resp, invoked
+}
+
+func TestRateLimitFilter(t *testing.T) {
+	revel.Config = config.NewContext()
+	revel.Config.SetOption("cache.ratelimit.limit", "3")
+	revel.Config.SetOption("cache.ratelimit.window", "1h")
+	Instance = NewInMemoryCache(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		resp, invoked := requestRateLimited("10.0.0.1", "")
+		if !invoked || resp.Header().Get("RateLimit-Remaining") != strconv.Itoa(2-i) || resp.Header().Get("RateLimit-Limit") != "3" {
+			t.Errorf("Expected request %d to be allowed, got %v", i, resp.Header())
+		}
+	}
+	resp, invoked := requestRateLimited("10.0.0.1", "")
+	if invoked || resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a 429 once the limit is reached, got %d %v", resp.Code, resp.Header())
+	}
+	if _, invoked = requestRateLimited("10.0.0.2", ""); !invoked {
+		t.Error("Expected another client to be allowed")
+	}
+}
+
+func TestRateLimitFilterSlidingWindow(t *testing.T) {
+	revel.Config = config.NewContext()
+	revel.Config.SetOption("cache.ratelimit.limit", "10")
+	revel.Config.SetOption("cache.ratelimit.key", "header:X-Api-Key")
+	Instance = NewInMemoryCache(time.Hour)
+
+	// The previous window is full, its requests count for the part still in the sliding window
+	window := time.Now().UnixNano() / int64(time.Minute)
+	sum := sha1.Sum([]byte("key"))
+	key := "revel/ratelimit:Api.Hotels:header:" + hex.EncodeToString(sum[:]) + ":"
+	if err := Set(key+strconv.FormatInt(window-1, 10), int64(10), time.Hour); err != nil {
+		t.Fatalf("Set failed: %s", err)
+	}
+	elapsed := time.Duration(time.Now().UnixNano() % int64(time.Minute))
+	allowed := 10 - int(10*(1-float64(elapsed)/float64(time.Minute)))
+
+	invoked := 0
+	for i := 0; i < 11; i++ {
+		if _, ok := requestRateLimited("10.0.0.1", "key"); ok {
+			invoked++
+		}
+	}
+	// The weight decreases while the requests are made
+	if invoked < allowed || invoked > allowed+1 {
+		t.Errorf("Expected about %d requests to be allowed, got %d", allowed, invoked)
+	}
+	if _, ok := requestRateLimited("10.0.0.1", "other"); !ok {
+		t.Error("Expected another API key to be allowed")
+	}
+}
+
+func TestRateLimitBySession(t *testing.T) {
+	c := &revel.Controller{ClientIP: "10.0.0.1"}
+
+	// A client dropping the session cookie is counted per IP
+	c.Session = session.NewSession()
+	if key := RateLimitBySession(c); key != "ip:10.0.0.1" {
+		t.Errorf("Expected the requests without a session id to be counted per IP, got %q", key)
+	}
+	id, _ := c.Session[session.SessionIDKey].(string)
+	if id == "" {
+		t.Fatal("Expected the session to be given an id")
+	}
+
+	// The session is sent back with its id
+	c.Session = session.Session{session.SessionIDKey: id}
+	if key := RateLimitBySession(c); key != "session:"+id {
+		t.Errorf("Expected the requests to be counted per session, got %q", key)
+	}
+}
@@ -20,7 +20,12 @@ type RedisCache struct {
 // NewRedisCache returns a new RedisCache with given parameters
 // until redigo supports sharding/clustering, only one host will be in hostList.
 func NewRedisCache(host string, password string, defaultExpiration time.Duration) RedisCache {
-	pool := &redis.Pool{
+	return RedisCache{newRedisPool(host, password), defaultExpiration}
+}
+
+// Returns the connection pool for the host, configured by the cache.redis.* settings.
+func newRedisPool(host string, password string) *redis.Pool {
+	return &redis.Pool{
 		MaxIdle:     revel.Config.IntDefault("cache.redis.maxidle", 5),
 		MaxActive:   revel.Config.IntDefault("cache.redis.maxactive", 0),
 		IdleTimeout: time.Duration(revel.Config.IntDefault("cache.redis.idletimeout", 240)) * time.Second,
@@ -56,7 +61,6 @@ func NewRedisCache(host string, password string, defaultExpiration time.Duration
 			return err
 		},
 	}
-	return RedisCache{pool, defaultExpiration}
 }
 
 func (c RedisCache) Set(key string, value interface{}, expires time.Duration) error {
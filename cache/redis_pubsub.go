@@ -0,0 +1,130 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisPubSub is a revel.PubSub using Redis channels, so the messages reach
+// the subscribers on every instance connected to the Redis server.
+type RedisPubSub struct {
+	pool *redis.Pool
+}
+
+// NewRedisPubSub returns a RedisPubSub for the host, the connections are
+// configured like the RedisCache.
+func NewRedisPubSub(host string, password string) *RedisPubSub {
+	return &RedisPubSub{newRedisPool(host, password)}
+}
+
+// Publish sends the message to the Redis channel named after the topic.
+func (ps *RedisPubSub) Publish(topic string, message []byte) error {
+	conn := ps.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, err := conn.Do("PUBLISH", topic, message)
+	return err
+}
+
+// Subscribe calls the handler with the messages of the Redis channel named
+// after the topic. If the connection is lost the subscription is renewed,
+// messages published while it is being renewed are lost.
+func (ps *RedisPubSub) Subscribe(topic string, handler func(message []byte)) (unsubscribe func(), err error) {
+	sub := &redisSubscription{pubsub: ps, topic: topic, handler: handler, done: make(chan struct{})}
+	if sub.conn, err = ps.subscribe(topic); err != nil {
+		return nil, err
+	}
+	go sub.receive()
+	return sub.close, nil
+}
+
+// Returns a connection subscribed to the channel.
+func (ps *RedisPubSub) subscribe(topic string) (redis.PubSubConn, error) {
+	conn := redis.PubSubConn{Conn: ps.pool.Get()}
+	if err := conn.Subscribe(topic); err != nil {
+		_ = conn.Close()
+		return conn, err
+	}
+	return conn, nil
+}
+
+// A subscription to a Redis channel.
+type redisSubscription struct {
+	pubsub    *RedisPubSub
+	topic     string
+	handler   func(message []byte)
+	conn      redis.PubSubConn // The subscribed connection, guarded by the mutex
+	mutex     sync.Mutex
+	done      chan struct{} // Closed when unsubscribed
+	closeOnce sync.Once
+}
+
+// Receives the messages until the subscription is closed.
+func (s *redisSubscription) receive() {
+	for {
+		s.mutex.Lock()
+		conn := s.conn
+		s.mutex.Unlock()
+
+		// The subscription waits for messages indefinitely, regardless of cache.redis.timeout.read
+		switch reply := conn.ReceiveWithTimeout(0).(type) {
+		case redis.Message:
+			s.handler(reply.Data)
+		case error:
+			_ = conn.Close()
+			if !s.renew(reply) {
+				return
+			}
+		}
+	}
+}
+
+// Replaces the failed connection, false is returned once unsubscribed.
+func (s *redisSubscription) renew(cause error) bool {
+	for {
+		select {
+		case <-s.done:
+			return false
+		default:
+		}
+		cacheLog.Error("receive: Redis subscription failed", "topic", s.topic, "error", cause)
+
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(time.Second):
+		}
+		conn, err := s.pubsub.subscribe(s.topic)
+		if err != nil {
+			cause = err
+			continue
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		select {
+		case <-s.done:
+			_ = conn.Close()
+			return false
+		default:
+		}
+		s.conn = conn
+		return true
+	}
+}
+
+// Ends the subscription.
+func (s *redisSubscription) close() {
+	s.closeOnce.Do(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
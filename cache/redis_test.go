@@ -64,3 +64,32 @@ func TestRedisCache_Add(t *testing.T) {
 func TestRedisCache_GetMulti(t *testing.T) {
 	testGetMulti(t, newRedisCache)
 }
+
+func TestRedisPubSub(t *testing.T) {
+	newRedisCache(t, time.Hour)
+	pubsub := NewRedisPubSub(redisTestServer, "")
+	received := make(chan string, 1)
+	unsubscribe, err := pubsub.Subscribe("revel.test", func(message []byte) {
+		received <- string(message)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %s", err)
+	}
+	defer unsubscribe()
+
+	// The subscription is confirmed asynchronously, publish until it is received
+	for i := 0; i < 50; i++ {
+		if err = pubsub.Publish("revel.test", []byte("hello")); err != nil {
+			t.Fatalf("Publish failed: %s", err)
+		}
+		select {
+		case message := <-received:
+			if message != "hello" {
+				t.Errorf("Unexpected message %s", message)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Error("No message received")
+}
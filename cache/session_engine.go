@@ -0,0 +1,215 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+const (
+	// The session key holding the user the session belongs to, see SetSessionUser.
+	SessionUserKey = "_USER"
+	// The session key holding the version of the user's sessions when it was bound.
+	sessionUserVersionField = "_USERV"
+	// The Controller.Args key holding the id of the session in the request cookie.
+	sessionDecodedIDArg = "cache.session.id"
+)
+
+// SessionCacheEngine keeps the session data in the cache, the session cookie
+// only holds the signed session id. Every request which uses the session
+// extends its expiration. Enable it with session.engine=revel-cache.
+type SessionCacheEngine struct {
+	ExpireAfterDuration time.Duration // The time a session is kept after its last use, 0 to end it with the browser session
+	BrowserSessionTTL   time.Duration // The time the data of a browser session is kept after its last use
+}
+
+func init() {
+	revel.RegisterSessionEngine(initSessionCacheEngine, "revel-cache")
+}
+
+// NewSessionCacheEngine returns an engine keeping the sessions for the duration.
+func NewSessionCacheEngine(expireAfterDuration time.Duration) *SessionCacheEngine {
+	return &SessionCacheEngine{ExpireAfterDuration: expireAfterDuration, BrowserSessionTTL: 24 * time.Hour}
+}
+
+// Called when the application starts, the expiration is read from
+// session.expires like for the cookie engine.
+func initSessionCacheEngine() revel.SessionEngine {
+	engine := NewSessionCacheEngine(30 * 24 * time.Hour)
+	var err error
+	if expiresString, ok := revel.Config.String("session.expires"); !ok {
+	} else if expiresString == session.SessionValueName {
+		engine.ExpireAfterDuration = 0
+	} else if engine.ExpireAfterDuration, err = time.ParseDuration(expiresString); err != nil {
+		panic(fmt.Errorf("session.expires invalid: %s", err))
+	}
+	engine.BrowserSessionTTL = revel.ConfigDuration("session.cache.browser.expires", engine.BrowserSessionTTL)
+	return engine
+}
+
+// Decode loads the session data for the id in the cookie.
+func (e *SessionCacheEngine) Decode(c *revel.Controller) {
+	c.Session = session.NewSession()
+	cookie, err := c.Request.Cookie(revel.CookiePrefix + session.SessionCookieSuffix)
+	if err != nil {
+		return
+	}
+	id, valid := parseSessionCookie(cookie.GetValue())
+	if !valid {
+		cacheLog.Warn("SessionCacheEngine.Decode: Session cookie signature failed")
+		return
+	}
+	c.Args[sessionDecodedIDArg] = id
+
+	data := map[string]string{}
+	if err = Get(sessionKey(id), &data); err != nil {
+		if err != ErrCacheMiss {
+			cacheLog.Error("SessionCacheEngine.Decode: Failed to load session", "error", err)
+		}
+		return
+	}
+	c.Session.Load(data)
+	c.Session[session.SessionIDKey] = id
+
+	// The session is dropped once it expired, or the sessions of its user are revoked
+	user, bound := data[SessionUserKey]
+	if c.Session.SessionTimeoutExpiredOrMissing() || (bound && data[sessionUserVersionField] != userSessionVersion(user)) {
+		for key := range c.Session {
+			delete(c.Session, key)
+		}
+		_ = Delete(sessionKey(id))
+	}
+}
+
+// Encode stores the session data and sets the cookie with its id, an empty
+// session is removed from the cache.
+func (e *SessionCacheEngine) Encode(c *revel.Controller) {
+	decodedID, _ := c.Args[sessionDecodedIDArg].(string)
+	if c.Session.Empty() {
+		if decodedID != "" {
+			_ = Delete(sessionKey(decodedID))
+		}
+		c.SetCookie(e.cookie("", time.Unix(1, 0), -1))
+		return
+	}
+
+	id := c.Session.ID()
+	if decodedID != "" && decodedID != id {
+		// The session was regenerated
+		_ = Delete(sessionKey(decodedID))
+	}
+	expires := c.Session.GetExpiration(e.ExpireAfterDuration)
+	ttl := e.ExpireAfterDuration
+	if expires.IsZero() {
+		c.Session[session.TimestampKey] = session.SessionValueName
+		ttl = e.BrowserSessionTTL
+	} else {
+		c.Session[session.TimestampKey] = strconv.FormatInt(expires.Unix(), 10)
+	}
+
+	data := c.Session.Serialize()
+	delete(data, session.SessionIDKey)
+	if err := Set(sessionKey(id), data, ttl); err != nil {
+		cacheLog.Error("SessionCacheEngine.Encode: Failed to store session", "error", err)
+		return
+	}
+	maxAge := int(e.ExpireAfterDuration.Seconds())
+	if expires.IsZero() {
+		maxAge = 0
+	}
+	c.SetCookie(e.cookie(id, expires, maxAge))
+}
+
+// Returns the session cookie.
+func (e *SessionCacheEngine) cookie(id string, expires time.Time, maxAge int) *http.Cookie {
+	value := ""
+	if id != "" {
+		value = revel.Sign(id) + "-" + id
+	}
+	return &http.Cookie{
+		Name:     revel.CookiePrefix + session.SessionCookieSuffix,
+		Value:    value,
+		Domain:   revel.CookieDomain,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   revel.CookieSecure,
+		SameSite: revel.CookieSameSite,
+		Expires:  expires.UTC(),
+		MaxAge:   maxAge,
+	}
+}
+
+// RegenerateSession gives the session a new id, keeping its data. Call it when
+// the user logs in, so an id obtained before (e.g. planted by an attacker)
+// does not give access to the session.
+func RegenerateSession(c *revel.Controller) {
+	id := uuid.New()
+	c.Session[session.SessionIDKey] = hex.EncodeToString(id[:])
+}
+
+// SetSessionUser regenerates the session and binds it to the user, so it is
+// revoked by RevokeUserSessions. For example:
+//
+//	func (c App) Login(username, password string) revel.Result {
+//		...
+//		cache.SetSessionUser(c.Controller, user.Username)
+//		return c.Redirect(App.Index)
+//	}
+func SetSessionUser(c *revel.Controller, user string) {
+	RegenerateSession(c)
+	c.Session[SessionUserKey] = user
+	c.Session[sessionUserVersionField] = userSessionVersion(user)
+}
+
+// RevokeUserSessions ends all the sessions bound to the user, on every
+// instance sharing the cache.
+func RevokeUserSessions(user string) (err error) {
+	key := userSessionVersionKey(user)
+	if _, err = Increment(key, 1); err == ErrCacheMiss {
+		err = Set(key, int64(1), ForEverNeverExpiry)
+	}
+	if err != nil {
+		cacheLog.Error("RevokeUserSessions: Failed to revoke sessions", "user", user, "error", err)
+	}
+	return
+}
+
+// Returns the cache key of the session data.
+func sessionKey(id string) string {
+	return "revel/session:" + id
+}
+
+// Returns the cache key of the version of the user's sessions.
+func userSessionVersionKey(user string) string {
+	return "revel/session/user:" + user
+}
+
+// Returns the current version of the user's sessions.
+func userSessionVersion(user string) string {
+	var version int64
+	if err := Get(userSessionVersionKey(user), &version); err != nil && err != ErrCacheMiss {
+		cacheLog.Error("SessionCacheEngine: Failed to get the session version", "user", user, "error", err)
+	}
+	return strconv.FormatInt(version, 10)
+}
+
+// Returns the id of a session cookie, if the signature is valid.
+func parseSessionCookie(value string) (id string, valid bool) {
+	hyphen := strings.Index(value, "-")
+	if hyphen == -1 || hyphen >= len(value)-1 {
+		return "", false
+	}
+	sig, id := value[:hyphen], value[hyphen+1:]
+	return id, revel.Verify(id, sig)
+}
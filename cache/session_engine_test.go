@@ -0,0 +1,135 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revel/config"
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+// Runs a request with the session cookie through the engine, the action is
+// called between decoding and encoding the session. The new session cookie is
+// returned.
+func requestSession(engine *SessionCacheEngine, cookie *http.Cookie, action func(c *revel.Controller)) *http.Cookie {
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(req)
+	context.Response.SetResponse(resp)
+	c := revel.NewController(context)
+
+	engine.Decode(c)
+	action(c)
+	engine.Encode(c)
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == revel.CookiePrefix+session.SessionCookieSuffix {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestSessionCacheEngine(t *testing.T) {
+	revel.Config = config.NewContext()
+	_ = revel.SetSecretKey([]byte("secret"))
+	defer func() {
+		_ = revel.SetSecretKey(nil)
+	}()
+	Instance = NewInMemoryCache(time.Hour)
+	engine := NewSessionCacheEngine(time.Hour)
+
+	cookie := requestSession(engine, nil, func(c *revel.Controller) {
+		c.Session["user"] = "alice"
+	})
+	if cookie == nil || strings.Contains(cookie.Value, "alice") || cookie.MaxAge != 3600 {
+		t.Fatalf("Expected a cookie holding only the session id, got %v", cookie)
+	}
+
+	requestSession(engine, cookie, func(c *revel.Controller) {
+		if c.Session["user"] != "alice" {
+			t.Errorf("Expected the session data from the cache, got %v", c.Session)
+		}
+	})
+
+	// A forged cookie is ignored
+	forged := &http.Cookie{Name: cookie.Name, Value: "0" + cookie.Value}
+	requestSession(engine, forged, func(c *revel.Controller) {
+		if !c.Session.Empty() {
+			t.Errorf("Expected an empty session for a forged cookie, got %v", c.Session)
+		}
+	})
+
+	// Clearing the session removes the data
+	id := cookie.Value[strings.Index(cookie.Value, "-")+1:]
+	expired := requestSession(engine, cookie, func(c *revel.Controller) {
+		for key := range c.Session {
+			delete(c.Session, key)
+		}
+	})
+	if expired == nil || expired.MaxAge >= 0 {
+		t.Errorf("Expected the cookie to be removed, got %v", expired)
+	}
+	data := map[string]string{}
+	if err := Get(sessionKey(id), &data); err != ErrCacheMiss {
+		t.Errorf("Expected the session to be removed from the cache, got %v", err)
+	}
+}
+
+func TestSessionCacheEngineUser(t *testing.T) {
+	revel.Config = config.NewContext()
+	Instance = NewInMemoryCache(time.Hour)
+	engine := NewSessionCacheEngine(time.Hour)
+
+	anonymous := requestSession(engine, nil, func(c *revel.Controller) {
+		c.Session["cart"] = "1"
+	})
+	login := func(c *revel.Controller) {
+		SetSessionUser(c, "alice")
+	}
+	first := requestSession(engine, anonymous, login)
+	if first.Value == anonymous.Value {
+		t.Error("Expected the session to be regenerated on login")
+	}
+	requestSession(engine, anonymous, func(c *revel.Controller) {
+		if !c.Session.Empty() {
+			t.Errorf("Expected the session before login to be gone, got %v", c.Session)
+		}
+	})
+	requestSession(engine, first, func(c *revel.Controller) {
+		if c.Session["cart"] != "1" || c.Session[SessionUserKey] != "alice" {
+			t.Errorf("Expected the session to be kept on login, got %v", c.Session)
+		}
+	})
+
+	second := requestSession(engine, nil, login)
+	if err := RevokeUserSessions("alice"); err != nil {
+		t.Fatalf("RevokeUserSessions failed: %s", err)
+	}
+	for _, cookie := range []*http.Cookie{first, second} {
+		requestSession(engine, cookie, func(c *revel.Controller) {
+			if !c.Session.Empty() {
+				t.Errorf("Expected the session to be revoked, got %v", c.Session)
+			}
+		})
+	}
+
+	// A session bound after the revocation is valid
+	third := requestSession(engine, nil, login)
+	requestSession(engine, third, func(c *revel.Controller) {
+		if c.Session[SessionUserKey] != "alice" {
+			t.Errorf("Expected the new session to be valid, got %v", c.Session)
+		}
+	})
+}
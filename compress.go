@@ -5,20 +5,30 @@
 package revel
 
 import (
+	"bytes"
 	"compress/gzip"
 	"compress/zlib"
 	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
-var compressionTypes = [...]string{
+// The compression types in order of preference, set via results.compressed.types.
+var compressionTypes = []string{
+	"br",
+	"zstd",
 	"gzip",
 	"deflate",
 }
 
-var compressableMimes = [...]string{
+// The mime types which are compressed, set via results.compressed.mimes, a
+// "type/*" entry matches every subtype.
+var compressableMimes = []string{
 	"text/plain",
 	"text/csv",
 	"text/html",
@@ -30,6 +40,47 @@ var compressableMimes = [...]string{
 	"application/rss+xml",
 	"application/javascript",
 	"application/x-javascript",
+	"image/svg+xml",
+}
+
+// The mime types which are already compressed, never compressed again even
+// when results.compressed.mimes matches them.
+var compressedMimes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+}
+
+// The file extensions of precompressed files by compression type.
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+func init() {
+	OnAppStart(func() {
+		if types := Config.StringDefault("results.compressed.types", ""); types != "" {
+			compressionTypes = splitConfigList(types)
+		}
+		if mimes := Config.StringDefault("results.compressed.mimes", ""); mimes != "" {
+			compressableMimes = splitConfigList(mimes)
+		}
+	})
 }
 
 // Local log instance for this class.
@@ -50,34 +101,38 @@ type CompressResponseWriter struct {
 	compressWriter     WriteFlusher          // The flushed writer
 	compressionType    string                // The compression type
 	headersWritten     bool                  // True if written
+	minSize            int                   // The smallest response compressed
+	buffer             *bytes.Buffer         // The content written while waiting for the minimum size
 	closeNotify        chan bool             // The notify channel to close
 	parentNotify       <-chan bool           // The parent chanel to receive the closed event
 	closed             bool                  // True if closed
 }
 
-// CompressFilter does compression of response body in gzip/deflate if
-// `results.compressed=true` in the app.conf.
+// CompressFilter does compression of response body in brotli/zstd/gzip/deflate
+// if `results.compressed=true` in the app.conf. Responses smaller than
+// `results.compressed.minsize` bytes are sent uncompressed. The responses of
+// a compressable type carry Vary: Accept-Encoding, whether they are
+// compressed or not.
 func CompressFilter(c *Controller, fc []Filter) {
 	if c.Response.Out.internalHeader.Server != nil && Config.BoolDefault("results.compressed", false) {
 		if c.Response.Status != http.StatusNoContent && c.Response.Status != http.StatusNotModified {
-			if found, compressType, compressWriter := detectCompressionType(c.Request, c.Response); found {
-				writer := CompressResponseWriter{
-					ControllerResponse: c.Response,
-					OriginalWriter:     c.Response.GetWriter(),
-					compressWriter:     compressWriter,
-					compressionType:    compressType,
-					headersWritten:     false,
-					closeNotify:        make(chan bool, 1),
-					closed:             false,
-				}
-				// Swap out the header with our own
-				writer.Header = NewBufferedServerHeader(c.Response.Out.internalHeader.Server)
-				c.Response.Out.internalHeader.Server = writer.Header
-				if w, ok := c.Response.GetWriter().(http.CloseNotifier); ok {
-					writer.parentNotify = w.CloseNotify()
-				}
-				c.Response.SetWriter(&writer)
+			// The compress writer is created once the response is known to be compressed
+			writer := CompressResponseWriter{
+				ControllerResponse: c.Response,
+				OriginalWriter:     c.Response.GetWriter(),
+				compressionType:    negotiateCompression(c.Request, compressionTypes),
+				headersWritten:     false,
+				minSize:            Config.IntDefault("results.compressed.minsize", 0),
+				closeNotify:        make(chan bool, 1),
+				closed:             false,
 			}
+			// Swap out the header with our own
+			writer.Header = NewBufferedServerHeader(c.Response.Out.internalHeader.Server)
+			c.Response.Out.internalHeader.Server = writer.Header
+			if w, ok := c.Response.GetWriter().(http.CloseNotifier); ok {
+				writer.parentNotify = w.CloseNotify()
+			}
+			c.Response.SetWriter(&writer)
 		} else {
 			compressLog.Debug("CompressFilter: Compression disabled for response ", "status", c.Response.Status)
 		}
@@ -93,43 +148,98 @@ func (c CompressResponseWriter) CloseNotify() <-chan bool {
 	return c.closeNotify
 }
 
-// Prepare the headers.
-func (c *CompressResponseWriter) prepareHeaders() {
-	if c.compressionType != "" {
-		responseMime := ""
-		if t := c.Header.Get("Content-Type"); len(t) > 0 {
-			responseMime = t[0]
-		}
-		responseMime = strings.TrimSpace(strings.SplitN(responseMime, ";", 2)[0])
-		shouldEncode := false
-
-		if len(c.Header.Get("Content-Encoding")) == 0 {
-			for _, compressableMime := range compressableMimes {
-				if responseMime == compressableMime {
-					shouldEncode = true
-					c.Header.Set("Content-Encoding", c.compressionType)
-					c.Header.Del("Content-Length")
-					break
-				}
-			}
+// Returns true if the response has a compressable mime type and is not
+// encoded already.
+func (c *CompressResponseWriter) compressableType() bool {
+	responseMime := ""
+	if t := c.Header.Get("Content-Type"); len(t) > 0 {
+		responseMime = t[0]
+	}
+	return len(c.Header.Get("Content-Encoding")) == 0 && isCompressableMime(responseMime)
+}
+
+// Returns true if the response should be compressed, the response must have
+// a compressable mime type, not be encoded or partial and be at least the
+// minimum size when the size is known.
+func (c *CompressResponseWriter) shouldCompress() bool {
+	// A partial response is a range of the uncompressed content
+	if !c.compressableType() || len(c.Header.Get("Content-Range")) > 0 {
+		return false
+	}
+	if length := c.Header.Get("Content-Length"); len(length) > 0 {
+		if size, err := strconv.Atoi(length[0]); err == nil && size < c.minSize {
+			return false
 		}
+	}
+	return true
+}
 
-		if !shouldEncode {
+// Prepare the headers, creating the compress writer if the response is
+// compressed.
+func (c *CompressResponseWriter) prepareHeaders() {
+	// Another client may be sent the response compressed
+	if c.compressableType() && !varyIncludes(c.Header.Get("Vary"), "Accept-Encoding") {
+		c.Header.Add("Vary", "Accept-Encoding")
+	}
+	if c.compressionType != "" && c.shouldCompress() {
+		var err error
+		if c.compressWriter, err = newCompressWriter(c.compressionType, c.OriginalWriter); err != nil {
+			compressLog.Error("prepareHeaders: Failed to create compress writer", "type", c.compressionType, "error", err)
 			c.compressWriter = nil
-			c.compressionType = ""
 		}
 	}
+	if c.compressWriter != nil {
+		c.Header.Set("Content-Encoding", c.compressionType)
+		c.Header.Del("Content-Length")
+		// The compressed content is not byte for byte the same, so the entity tag is weakened
+		if etag := c.Header.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag[0], "W/") {
+			c.Header.Set("ETag", "W/"+etag[0])
+		}
+	} else {
+		c.compressionType = ""
+	}
 	c.Header.Release()
 }
 
+// Writes the headers, unless the response has no Content-Length and is
+// buffered until it is known to reach results.compressed.minsize.
+func (c *CompressResponseWriter) writeHeaders() {
+	if c.headersWritten || c.buffer != nil {
+		return
+	}
+	if c.compressionType != "" && c.minSize > 0 && len(c.Header.Get("Content-Length")) == 0 && c.shouldCompress() {
+		c.buffer = &bytes.Buffer{}
+		return
+	}
+	c.headersWritten = true
+	c.prepareHeaders()
+}
+
+// Ends the buffering, the buffered content is compressed if it reached the
+// minimum size. The final flag is set when the buffer is the whole response.
+func (c *CompressResponseWriter) writeBuffer(final bool) (err error) {
+	buffer := c.buffer
+	c.buffer = nil
+	if buffer.Len() < c.minSize {
+		c.compressionType = ""
+		if final {
+			c.Header.Set("Content-Length", strconv.Itoa(buffer.Len()))
+		}
+	}
+	c.headersWritten = true
+	c.prepareHeaders()
+	_, err = c.write(buffer.Bytes())
+	return
+}
+
 // Write the headers.
 func (c *CompressResponseWriter) WriteHeader(status int) {
 	if c.closed {
 		return
 	}
-	c.headersWritten = true
-	c.prepareHeaders()
+	// The buffered header holds the status until it is released
 	c.Header.SetStatus(status)
+	c.writeHeaders()
 }
 
 // Close the writer.
@@ -137,6 +247,11 @@ func (c *CompressResponseWriter) Close() error {
 	if c.closed {
 		return nil
 	}
+	if c.buffer != nil {
+		if err := c.writeBuffer(true); err != nil {
+			compressLog.Error("Close: Error writing buffered response", "error", err)
+		}
+	}
 	if !c.headersWritten {
 		c.prepareHeaders()
 	}
@@ -175,94 +290,153 @@ func (c *CompressResponseWriter) Write(b []byte) (int, error) {
 		return 0, io.ErrClosedPipe
 	}
 
-	if !c.headersWritten {
-		c.prepareHeaders()
-		c.headersWritten = true
+	c.writeHeaders()
+	if c.buffer != nil {
+		c.buffer.Write(b)
+		if c.buffer.Len() >= c.minSize {
+			if err := c.writeBuffer(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
 	}
+	return c.write(b)
+}
+
+// Writes to the compress writer, or the original writer if not compressing.
+func (c *CompressResponseWriter) write(b []byte) (int, error) {
 	if c.compressionType != "" {
 		return c.compressWriter.Write(b)
 	}
 	return c.OriginalWriter.Write(b)
 }
 
-// DetectCompressionType method detects the compression type
-// from header "Accept-Encoding".
-func detectCompressionType(req *Request, resp *Response) (found bool, compressionType string, compressionKind WriteFlusher) {
-	if Config.BoolDefault("results.compressed", false) {
-		acceptedEncodings := strings.Split(req.GetHttpHeader("Accept-Encoding"), ",")
-
-		largestQ := 0.0
-		chosenEncoding := len(compressionTypes)
-
-		// I have fixed one edge case for issue #914
-		// But it's better to cover all possible edge cases or
-		// Adapt to https://github.com/golang/gddo/blob/master/httputil/header/header.go#L172
-		for _, encoding := range acceptedEncodings {
-			encoding = strings.TrimSpace(encoding)
-			encodingParts := strings.SplitN(encoding, ";", 2)
+// Flush writes any buffered output to the client, so streamed responses
+// (e.g. server sent events) are delivered as they are written.
+func (c *CompressResponseWriter) Flush() {
+	if c.closed {
+		return
+	}
+	if c.buffer != nil {
+		if err := c.writeBuffer(false); err != nil {
+			compressLog.Error("Flush: Error writing buffered response", "error", err)
+		}
+	}
+	if !c.headersWritten {
+		c.prepareHeaders()
+		c.headersWritten = true
+	}
+	if c.compressionType != "" {
+		if err := c.compressWriter.Flush(); err != nil {
+			compressLog.Error("Flush: Error flushing compress writer", "type", c.compressionType, "error", err)
+		}
+	}
+	if flusher, ok := c.OriginalWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
 
-			// If we are the format "gzip;q=0.8"
-			if len(encodingParts) > 1 {
-				q := strings.TrimSpace(encodingParts[1])
-				if len(q) == 0 || !strings.HasPrefix(q, "q=") {
-					continue
-				}
+// Returns the compression type of the available types which the client
+// accepts with the highest quality, ties are decided by the order of the types.
+// An empty string is returned if none are accepted.
+func negotiateCompression(req *Request, available []string) (compressionType string) {
+	header := req.GetHttpHeader("Accept-Encoding")
+	if header == "" {
+		return
+	}
+	accepted := ParseAccept(strings.Replace(strings.ToLower(header), "x-gzip", "gzip", -1))
+	var best float32
+	for _, t := range available {
+		if quality := accepted.Quality(t); quality > best {
+			compressionType, best = t, quality
+		}
+	}
+	return
+}
 
-				// Strip off the q=
-				num, err := strconv.ParseFloat(q[2:], 32)
-				if err != nil {
-					continue
-				}
+// Returns the writer which compresses to the writer, at the level set via
+// results.compressed.<type>.level.
+func newCompressWriter(compressionType string, writer io.Writer) (WriteFlusher, error) {
+	key := "results.compressed." + compressionType + ".level"
+	switch compressionType {
+	case "br":
+		return brotli.NewWriterLevel(writer, Config.IntDefault(key, brotli.DefaultCompression)), nil
+	case "zstd":
+		level := zstd.SpeedDefault
+		if l, found := Config.Int(key); found {
+			level = zstd.EncoderLevelFromZstd(l)
+		}
+		return zstd.NewWriter(writer, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	case "gzip":
+		return gzip.NewWriterLevel(writer, Config.IntDefault(key, gzip.DefaultCompression))
+	case "deflate":
+		return zlib.NewWriterLevel(writer, Config.IntDefault(key, zlib.DefaultCompression))
+	}
+	return nil, nil
+}
 
-				if num >= largestQ && num > 0 {
-					if encodingParts[0] == "*" {
-						chosenEncoding = 0
-						largestQ = num
-						continue
-					}
-					for i, encoding := range compressionTypes {
-						if encoding == encodingParts[0] {
-							if i < chosenEncoding {
-								largestQ = num
-								chosenEncoding = i
-							}
-							break
-						}
-					}
-				}
-			} else {
-				// If we can accept anything, chose our preferred method.
-				if encodingParts[0] == "*" {
-					chosenEncoding = 0
-					largestQ = 1
-					break
-				}
-				// This is for just plain "gzip"
-				for i, encoding := range compressionTypes {
-					if encoding == encodingParts[0] {
-						if i < chosenEncoding {
-							largestQ = 1.0
-							chosenEncoding = i
-						}
-						break
-					}
-				}
+// Returns true if the Vary header values name the header.
+func varyIncludes(vary []string, name string) bool {
+	for _, value := range vary {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return true
 			}
 		}
+	}
+	return false
+}
+
+// Returns true if the content of the mime type should be compressed.
+func isCompressableMime(mimeType string) bool {
+	return mimeListMatches(compressableMimes, mimeType) && !mimeListMatches(compressedMimes, mimeType)
+}
 
-		if largestQ == 0 {
-			return
+// Returns true if the mime type is in the list, "type/*" entries match any subtype.
+func mimeListMatches(list []string, mimeType string) bool {
+	typ, subtype := splitMimeType(mimeType)
+	for _, entry := range list {
+		entryType, entrySubtype := splitMimeType(entry)
+		if entryType == typ && (entrySubtype == "*" || entrySubtype == subtype) {
+			return true
 		}
+	}
+	return false
+}
 
-		compressionType = compressionTypes[chosenEncoding]
+// Opens the precompressed variant of the file (e.g. "app.js.br") with the
+// compression the client prefers, nil is returned if there is none. The
+// variants are used unless results.compressed.precompressed=false, varies is
+// true if the file has any.
+func openPrecompressed(req *Request, filename string) (file *os.File, compressionType string, varies bool) {
+	if !Config.BoolDefault("results.compressed.precompressed", true) {
+		return
+	}
+	available := make([]string, 0, len(precompressedExtensions))
+	for _, t := range compressionTypes {
+		if ext, found := precompressedExtensions[t]; found {
+			if info, err := os.Stat(filename + ext); err == nil && info.Mode().IsRegular() {
+				available = append(available, t)
+			}
+		}
+	}
+	varies = len(available) > 0
+	if compressionType = negotiateCompression(req, available); compressionType == "" {
+		return
+	}
+	file, err := os.Open(filename + precompressedExtensions[compressionType])
+	if err != nil {
+		compressLog.Warn("openPrecompressed: Failed to open precompressed file", "file", filename, "type", compressionType, "error", err)
+		return nil, "", varies
+	}
+	return
+}
 
-		switch compressionType {
-		case "gzip":
-			compressionKind = gzip.NewWriter(resp.GetWriter())
-			found = true
-		case "deflate":
-			compressionKind = zlib.NewWriter(resp.GetWriter())
-			found = true
+// Splits a comma separated configuration value.
+func splitConfigList(value string) (list []string) {
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
 		}
 	}
 	return
@@ -306,14 +480,14 @@ func (bsh *BufferedServerHeader) Set(key string, value string) {
 	}
 }
 
-// Add (append) to a key this value.
+// Add (append) to a key this value, after the values the key already has.
 func (bsh *BufferedServerHeader) Add(key string, value string) {
 	if bsh.released {
-		bsh.original.Set(key, value)
+		bsh.original.Add(key, value)
 	} else {
-		old := []string{}
-		if v, found := bsh.headerMap[key]; found {
-			old = v
+		old, found := bsh.headerMap[key]
+		if !found {
+			old = append([]string{}, bsh.original.Get(key)...)
 		}
 		bsh.headerMap[key] = append(old, value)
 	}
@@ -324,7 +498,8 @@ func (bsh *BufferedServerHeader) Del(key string) {
 	if bsh.released {
 		bsh.original.Del(key)
 	} else {
-		delete(bsh.headerMap, key)
+		// The key is released without values, so it is removed from the original
+		bsh.headerMap[key] = nil
 	}
 }
 
@@ -333,7 +508,7 @@ func (bsh *BufferedServerHeader) Get(key string) (value []string) {
 	if bsh.released {
 		value = bsh.original.Get(key)
 	} else {
-		if v, found := bsh.headerMap[key]; found && len(v) > 0 {
+		if v, found := bsh.headerMap[key]; found {
 			value = v
 		} else {
 			value = bsh.original.Get(key)
@@ -347,11 +522,15 @@ func (bsh *BufferedServerHeader) GetKeys() (value []string) {
 	if bsh.released {
 		value = bsh.original.GetKeys()
 	} else {
-		value = bsh.original.GetKeys()
-		for key := range bsh.headerMap {
-			found := false
-			for _, v := range value {
-				if v == key {
+		for _, key := range bsh.original.GetKeys() {
+			if v, found := bsh.headerMap[key]; !found || len(v) > 0 {
+				value = append(value, key)
+			}
+		}
+		for key, v := range bsh.headerMap {
+			found := len(v) == 0
+			for _, k := range value {
+				if k == key {
 					found = true
 					break
 				}
@@ -373,12 +552,14 @@ func (bsh *BufferedServerHeader) SetStatus(statusCode int) {
 	}
 }
 
-// Release the header and push the results to the original.
+// Release the header and push the results to the original. The values of a
+// buffered key replace those of the original, all of them are kept.
 func (bsh *BufferedServerHeader) Release() {
 	bsh.released = true
 	for k, v := range bsh.headerMap {
+		bsh.original.Del(k)
 		for _, r := range v {
-			bsh.original.Set(k, r)
+			bsh.original.Add(k, r)
 		}
 	}
 	for _, c := range bsh.cookieList {
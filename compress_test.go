@@ -5,9 +5,20 @@
 package revel
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 // Test that the render response is as expected.
@@ -59,3 +70,197 @@ func BenchmarkRenderUnCompressed(b *testing.B) {
 		hotels.Show(3).Apply(c.Request, c.Response)
 	}
 }
+
+func TestNegotiateCompression(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"x-gzip":                    "gzip",
+		"gzip, deflate, br":         "br",
+		"gzip;q=1, br;q=0.5":        "gzip",
+		"br;q=0, gzip;q=0.1":        "gzip",
+		"zstd, gzip":                "zstd",
+		"*":                         "br",
+		"*;q=0.5, br;q=0, zstd;q=0": "gzip",
+		"deflate;q=abc":             "deflate",
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		c := NewTestController(nil, req)
+		if compressionType := negotiateCompression(c.Request, compressionTypes); compressionType != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, header, compressionType)
+		}
+	}
+}
+
+// Returns the response to the request with the Accept-Encoding for the result
+// of the action, after passing it through the CompressFilter.
+func compressedResponse(acceptEncoding string, action func(c *Controller) Result) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/public/file", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	CompressFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		c.Result = action(c)
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	if closer, ok := c.Response.GetWriter().(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return resp
+}
+
+func TestCompressFilterTypes(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("results.compressed", "true")
+	defer Config.SetOption("results.compressed", "false")
+	text := strings.Repeat("Hello compression. ", 100)
+
+	for compressionType, decoder := range map[string]func(io.Reader) (io.Reader, error){
+		"br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			d, err := zstd.NewReader(r)
+			return d, err
+		},
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	} {
+		Config.SetOption("results.compressed."+compressionType+".level", "1")
+		resp := compressedResponse(compressionType, func(c *Controller) Result {
+			return c.RenderText(text)
+		})
+		Config.SetOption("results.compressed."+compressionType+".level", "")
+		if encoding := resp.Header().Get("Content-Encoding"); encoding != compressionType {
+			t.Errorf("Expected %s encoding, got %q", compressionType, encoding)
+			continue
+		}
+		reader, err := decoder(resp.Body)
+		if err != nil {
+			t.Errorf("Failed to decode %s: %s", compressionType, err)
+			continue
+		}
+		if content, err := ioutil.ReadAll(reader); err != nil || string(content) != text {
+			t.Errorf("Failed to decode %s: %s", compressionType, err)
+		}
+	}
+}
+
+func TestCompressFilterMinSize(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("results.compressed", "true")
+	Config.SetOption("results.compressed.minsize", "100")
+	defer Config.SetOption("results.compressed", "false")
+	defer Config.SetOption("results.compressed.minsize", "0")
+
+	resp := compressedResponse("gzip", func(c *Controller) Result {
+		return c.RenderText("short")
+	})
+	if resp.Header().Get("Content-Encoding") != "" || resp.Body.String() != "short" || resp.Header().Get("Content-Length") != "5" {
+		t.Errorf("Expected a small response to be uncompressed, got %q %q", resp.Header().Get("Content-Encoding"), resp.Body.String())
+	}
+	if vary := resp.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding on an uncompressed response, got %v", vary)
+	}
+	resp = compressedResponse("", func(c *Controller) Result {
+		return c.RenderText(strings.Repeat("long", 50))
+	})
+	if resp.Header().Get("Content-Encoding") != "" || resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding without compression, got %v", resp.Header())
+	}
+
+	resp = compressedResponse("gzip", func(c *Controller) Result {
+		return c.RenderText(strings.Repeat("long", 50))
+	})
+	if resp.Header().Get("Content-Encoding") != "gzip" || resp.Code != http.StatusOK {
+		t.Errorf("Expected a large response to be compressed, got %q %d", resp.Header().Get("Content-Encoding"), resp.Code)
+	}
+}
+
+func TestCompressFilterVary(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("results.compressed", "true")
+	defer Config.SetOption("results.compressed", "false")
+
+	for _, acceptEncoding := range []string{"gzip", ""} {
+		req, _ := http.NewRequest("GET", "/public/file", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp := httptest.NewRecorder()
+		c := NewTestController(resp, req)
+		// As the CORSFilter does before the compression, and RenderNegotiated after it
+		c.Response.Out.internalHeader.Add("Vary", "Origin")
+		CompressFilter(c, []Filter{func(c *Controller, _ []Filter) {
+			c.Response.Out.internalHeader.Add("Vary", "Accept")
+			c.Result = c.RenderText(strings.Repeat("vary", 50))
+		}})
+		c.Result.Apply(c.Request, c.Response)
+		if closer, ok := c.Response.GetWriter().(io.Closer); ok {
+			_ = closer.Close()
+		}
+		if vary := strings.Join(resp.Header().Values("Vary"), ", "); vary != "Origin, Accept, Accept-Encoding" {
+			t.Errorf("Expected all the Vary values for %q, got %q", acceptEncoding, vary)
+		}
+	}
+}
+
+func TestCompressFilterCompressedMimes(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("results.compressed", "true")
+	defer Config.SetOption("results.compressed", "false")
+	defer func(mimes []string) { compressableMimes = mimes }(compressableMimes)
+	compressableMimes = []string{"image/*"}
+
+	for name, expected := range map[string]string{"image.svg": "gzip", "image.png": "", "file.txt": ""} {
+		var writer *CompressResponseWriter
+		resp := compressedResponse("gzip", func(c *Controller) Result {
+			writer, _ = c.Response.GetWriter().(*CompressResponseWriter)
+			return c.RenderBinary(strings.NewReader("content"), name, Inline, time.Now())
+		})
+		if encoding := resp.Header().Get("Content-Encoding"); encoding != expected {
+			t.Errorf("Expected %q encoding for %s, got %q", expected, name, encoding)
+		}
+		if varies := resp.Header().Get("Vary") != ""; varies != (expected != "") {
+			t.Errorf("Expected Vary only on the compressable types, got %q for %s", resp.Header().Get("Vary"), name)
+		}
+		// The writer is only created for a compressed response
+		if writer == nil || (writer.compressWriter != nil) != (expected != "") {
+			t.Errorf("Unexpected compress writer for %s", name)
+		}
+	}
+}
+
+func TestRenderFileNamePrecompressed(t *testing.T) {
+	startFakeBookingApp()
+	dir, err := ioutil.TempDir("", "revel-precompressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.js")
+	for name, content := range map[string]string{"app.js": "plain", "app.js.br": "brotli", "app.js.gz": "gzip"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for acceptEncoding, expected := range map[string]string{"": "plain", "gzip": "gzip", "gzip, br": "brotli", "zstd": "plain"} {
+		req, _ := http.NewRequest("GET", "/public/app.js", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp := httptest.NewRecorder()
+		c := NewTestController(resp, req)
+		c.RenderFileName(filename, Inline).Apply(c.Request, c.Response)
+
+		if resp.Body.String() != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, acceptEncoding, resp.Body.String())
+		}
+		if contentType := resp.Header().Get("Content-Type"); !strings.Contains(contentType, "javascript") {
+			t.Errorf("Expected the content type of the original file, got %q", contentType)
+		}
+		if encoding := resp.Header().Get("Content-Encoding"); (expected == "plain") != (encoding == "") {
+			t.Errorf("Unexpected encoding %q for %q", encoding, acceptEncoding)
+		}
+		if vary := resp.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Expected Vary: Accept-Encoding for %q, got %q", acceptEncoding, vary)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A byte range of the content.
+type byteRange struct {
+	start, length int64
+}
+
+// Returns the Content-Range header for the range.
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+var errUnsatisfiableRange = errors.New("Range not satisfiable")
+
+// Evaluates the preconditions of the request (RFC 7232 section 6) against the
+// entity tag and modification time of the content. The status which should
+// be returned instead of the content is returned, or 0 if the content should
+// be returned.
+func checkPreconditions(req *Request, etag string, modtime time.Time) int {
+	if ifMatch := req.GetHttpHeader("If-Match"); ifMatch != "" {
+		if !ETagListMatches(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.GetHttpHeader("If-Unmodified-Since")); err == nil && !modtime.IsZero() {
+		if modtime.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := req.Method == "GET" || req.Method == "HEAD"
+	if ifNoneMatch := req.GetHttpHeader("If-None-Match"); ifNoneMatch != "" {
+		if ETagListMatches(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.GetHttpHeader("If-Modified-Since")); err == nil && safe && !modtime.IsZero() {
+		if !modtime.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// Returns true if the Range header should be honored, an If-Range header
+// must match the entity tag (strongly) or the modification time exactly.
+func checkIfRange(req *Request, etag string, modtime time.Time) bool {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+	ifRange := req.GetHttpHeader("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagMatches(ifRange, etag, true)
+	}
+	since, err := http.ParseTime(ifRange)
+	return err == nil && !modtime.IsZero() && modtime.Truncate(time.Second).Equal(since)
+}
+
+// ETagListMatches returns true if any of the comma separated entity tags in
+// the header (e.g. If-None-Match) match the entity tag, "*" matches any entity
+// tag. The weak comparison ignores the W/ prefix of the tags.
+func ETagListMatches(header, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || etagMatches(candidate, etag, strong) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compares the entity tags, a strong comparison fails if either tag is weak.
+func etagMatches(a, b string, strong bool) bool {
+	if strong {
+		return a == b && !strings.HasPrefix(a, "W/")
+	}
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// Parses a Range header (RFC 7233) for content of the size. Ranges which
+// start beyond the content are dropped, if none remain errUnsatisfiableRange
+// is returned.
+func parseRange(header string, size int64) (ranges []byteRange, err error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, errors.New("Invalid range unit")
+	}
+	for _, spec := range strings.Split(header[len("bytes="):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		dash := strings.Index(spec, "-")
+		if dash < 0 {
+			return nil, errors.New("Invalid range")
+		}
+		first, last := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+		var r byteRange
+		if first == "" {
+			// A suffix range, the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errors.New("Invalid range")
+			}
+			if n > size {
+				n = size
+			}
+			r = byteRange{size - n, n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errors.New("Invalid range")
+			}
+			if start >= size {
+				continue
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, errors.New("Invalid range")
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			r = byteRange{start, end - start + 1}
+		}
+		if r.length > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	return
+}
+
+// Advances the reader to the offset, by seeking if possible.
+func skipReader(reader io.Reader, offset int64) (err error) {
+	if offset == 0 {
+		return
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err = seeker.Seek(offset, io.SeekStart)
+		return
+	}
+	_, err = io.CopyN(ioutil.Discard, reader, offset)
+	return
+}
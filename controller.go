@@ -5,6 +5,7 @@
 package revel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -125,6 +126,26 @@ func (c *Controller) SetCookie(cookie *http.Cookie) {
 	c.Response.Out.internalHeader.SetCookie(cookie.String())
 }
 
+// Context returns the context of the request, it carries the deadline set by
+// the TimeoutFilter and is canceled when the client goes away. Pass it to the
+// database and other downstream calls so they stop when the action overruns.
+func (c *Controller) Context() context.Context {
+	if ctx := c.Request.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// StreamContext returns the context of the request without the deadline of
+// the TimeoutFilter, it is canceled when the client goes away. Use it for the
+// work which outlives the action, such as producing the events of a stream.
+func (c *Controller) StreamContext() context.Context {
+	if ctx, ok := c.Args[timeoutParentArg].(context.Context); ok {
+		return ctx
+	}
+	return c.Context()
+}
+
 type ErrorCoder interface {
 	HTTPCode() int
 }
@@ -297,8 +318,20 @@ func (c *Controller) Forbidden(msg string, objs ...interface{}) Result {
 
 // RenderFileName returns a file indicated by the path as provided via the filename.
 // It can be either displayed inline or downloaded as an attachment.
-// The name and size are taken from the file info.
+// The name and size are taken from the file info. If there is a precompressed
+// variant of the file (e.g. "app.js.br" or "app.js.gz") which the client
+// accepts it is returned instead, with the Content-Encoding set.
 func (c *Controller) RenderFileName(filename string, delivery ContentDisposition) Result {
+	f, compressionType, varies := openPrecompressed(c.Request, filename)
+	if varies {
+		c.Response.Out.internalHeader.Add("Vary", "Accept-Encoding")
+	}
+	if f != nil {
+		result := c.RenderFile(f, delivery).(*BinaryResult)
+		result.Name = filepath.Base(filename)
+		c.Response.Out.internalHeader.Set("Content-Encoding", compressionType)
+		return result
+	}
 	f, err := os.Open(filename)
 	if err != nil {
 		c.Log.Errorf("Cant open file: %v", err)
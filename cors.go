@@ -0,0 +1,250 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The filter option holding the *CORSPolicy of an action, see
+// FilterConfigurator.CORS.
+const CORSFilterOption = "cors"
+
+// CORSPolicy holds the cross-origin requests allowed for an action.
+type CORSPolicy struct {
+	// The allowed origins, either exact ("https://example.com"), a wildcard
+	// ("*" or "https://*.example.com") or a regular expression between
+	// slashes ("/^https://(www|api)\.example\.com$/")
+	Origins []string
+	// The allowed methods
+	Methods []string
+	// The allowed request headers, "*" allows those requested
+	Headers []string
+	// The response headers exposed to the scripts
+	ExposeHeaders []string
+	// True to allow cookies and credentials, not with the "*" origin
+	Credentials bool
+	// The time the browser may cache the preflight response
+	MaxAge time.Duration
+
+	origins []*regexp.Regexp // The compiled origins
+}
+
+var (
+	corsLog = RevelLog.New("section", "cors")
+
+	// The policy of the actions without a CORS option, read from cors.* in app.conf
+	defaultCORSPolicy *CORSPolicy
+)
+
+func init() {
+	OnAppStart(initCORSPolicy)
+}
+
+// Reads the default policy from the configuration.
+func initCORSPolicy() {
+	defaultCORSPolicy = NewCORSPolicy(CORSPolicy{
+		Origins:       splitConfigList(Config.StringDefault("cors.origins", "")),
+		Methods:       splitConfigList(Config.StringDefault("cors.methods", "GET, HEAD, POST, PUT, PATCH, DELETE")),
+		Headers:       splitConfigList(Config.StringDefault("cors.headers", "Accept, Accept-Language, Content-Type, Authorization, X-Requested-With")),
+		ExposeHeaders: splitConfigList(Config.StringDefault("cors.expose", "")),
+		Credentials:   Config.BoolDefault("cors.credentials", false),
+		MaxAge:        ConfigDuration("cors.maxage", 10*time.Minute),
+	})
+}
+
+// NewCORSPolicy returns the policy with its origins compiled, it panics if an
+// origin is an invalid regular expression. The credentials are disabled if
+// any origin ("*") is allowed, as any website could read the responses of the
+// logged in users otherwise.
+func NewCORSPolicy(policy CORSPolicy) *CORSPolicy {
+	if policy.Credentials && policy.allowsAnyOrigin() {
+		corsLog.Error("NewCORSPolicy: Credentials are not allowed with the origin *, they are disabled", "origins", policy.Origins)
+		policy.Credentials = false
+	}
+	policy.origins = make([]*regexp.Regexp, len(policy.Origins))
+	for i, origin := range policy.Origins {
+		var pattern string
+		if len(origin) > 2 && strings.HasPrefix(origin, "/") && strings.HasSuffix(origin, "/") {
+			pattern = origin[1 : len(origin)-1]
+		} else {
+			pattern = "^" + strings.Replace(regexp.QuoteMeta(origin), `\*`, ".*", -1) + "$"
+		}
+		policy.origins[i] = regexp.MustCompile(pattern)
+	}
+	return &policy
+}
+
+// CORS sets the cross-origin requests allowed for the controller or action,
+// overriding the cors.* settings of app.conf.
+//
+//	revel.FilterController(Api{}).
+//		CORS(revel.CORSPolicy{
+//			Origins:     []string{"https://*.example.com"},
+//			Methods:     []string{"GET", "POST"},
+//			Credentials: true,
+//		})
+func (conf FilterConfigurator) CORS(policy CORSPolicy) FilterConfigurator {
+	return conf.SetOption(CORSFilterOption, NewCORSPolicy(policy))
+}
+
+// CORSFilter answers the cross-origin requests allowed by the policy of the
+// action. Add it to revel.Filters before the RouterFilter, so it answers the
+// preflight requests of any route without OPTIONS routes:
+//
+//	revel.Filters = []revel.Filter{
+//		revel.PanicFilter,
+//		revel.CORSFilter,
+//		revel.RouterFilter,
+//		...
+//	}
+//
+// The policy is read from app.conf, cors.origins (comma separated, none by
+// default), cors.methods, cors.headers, cors.expose, cors.credentials and
+// cors.maxage (e.g. "10m"), or set per action with FilterConfigurator.CORS.
+func CORSFilter(c *Controller, fc []Filter) {
+	origin := c.Request.GetHttpHeader("Origin")
+	if origin == "" {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	requestMethod := c.Request.GetHttpHeader("Access-Control-Request-Method")
+	if c.Request.Method == "OPTIONS" && requestMethod != "" {
+		if corsPreflight(c, origin, requestMethod) {
+			return
+		}
+		fc[0](c, fc[1:])
+		return
+	}
+
+	fc[0](c, fc[1:])
+	// The result has not been applied yet, the router has set the action
+	policy := corsActionPolicy(c)
+	if policy.variesByOrigin() {
+		c.Response.Out.internalHeader.Add("Vary", "Origin")
+	}
+	if policy.allowsOrigin(origin) {
+		policy.setOriginHeaders(c, origin)
+		if len(policy.ExposeHeaders) > 0 {
+			c.Response.Out.internalHeader.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+		}
+	}
+}
+
+// Answers the preflight request with the policy of the action routed for the
+// requested method, false is returned if there is no such route.
+func corsPreflight(c *Controller, origin, requestMethod string) bool {
+	method := c.Request.Method
+	c.Request.Method = requestMethod
+	route := MainRouter.Route(c.Request)
+	c.Request.Method = method
+	if route == nil || route.Action == httpStatusCode ||
+		c.SetTypeAction(route.ControllerName, route.MethodName, route.TypeOfController) != nil {
+		return false
+	}
+
+	policy := corsActionPolicy(c)
+	if !policy.allowsOrigin(origin) || !policy.allowsMethod(requestMethod) {
+		corsLog.Warn("CORSFilter: Preflight request denied", "action", c.Action, "origin", origin, "method", requestMethod)
+		c.Result = statusResult{http.StatusForbidden}
+		return true
+	}
+
+	header := c.Response.Out.internalHeader
+	if policy.variesByOrigin() {
+		header.Add("Vary", "Origin")
+	}
+	policy.setOriginHeaders(c, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+	if requested := c.Request.GetHttpHeader("Access-Control-Request-Headers"); requested != "" && policy.allowsAnyHeader() {
+		header.Set("Access-Control-Allow-Headers", requested)
+		header.Add("Vary", "Access-Control-Request-Headers")
+	} else if len(policy.Headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+	}
+	if policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+	}
+	c.Result = statusResult{http.StatusNoContent}
+	return true
+}
+
+// Returns the policy of the action, or the default policy.
+func corsActionPolicy(c *Controller) *CORSPolicy {
+	if value, found := c.FilterOption(CORSFilterOption); found {
+		if policy, ok := value.(*CORSPolicy); ok {
+			return policy
+		}
+	}
+	if defaultCORSPolicy == nil {
+		return &CORSPolicy{}
+	}
+	return defaultCORSPolicy
+}
+
+// Sets the headers allowing the origin.
+func (policy *CORSPolicy) setOriginHeaders(c *Controller, origin string) {
+	header := c.Response.Out.internalHeader
+	if policy.variesByOrigin() {
+		header.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if policy.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Returns true if the response depends on the origin, the browsers require
+// the origin itself rather than "*" with credentials, which a policy allowing
+// any origin never has.
+func (policy *CORSPolicy) variesByOrigin() bool {
+	return len(policy.Origins) > 0 && (policy.Credentials || !policy.allowsAnyOrigin())
+}
+
+// Returns true if the origin is allowed.
+func (policy *CORSPolicy) allowsOrigin(origin string) bool {
+	for _, pattern := range policy.origins {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if any origin is allowed.
+func (policy *CORSPolicy) allowsAnyOrigin() bool {
+	for _, origin := range policy.Origins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the method is allowed.
+func (policy *CORSPolicy) allowsMethod(method string) bool {
+	for _, allowed := range policy.Methods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if any request header is allowed.
+func (policy *CORSPolicy) allowsAnyHeader() bool {
+	for _, header := range policy.Headers {
+		if header == "*" {
+			return true
+		}
+	}
+	return false
+}
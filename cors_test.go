@@ -0,0 +1,123 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Runs the request through the CORSFilter and the router, returning the
+// response and true if the action was invoked.
+func requestCORS(method, path string, headers map[string]string) (*httptest.ResponseRecorder, bool) {
+	req, _ := http.NewRequest(method, "http://localhost"+path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	c.Params = &Params{}
+	invoked := false
+	CORSFilter(c, []Filter{RouterFilter, func(c *Controller, _ []Filter) {
+		invoked = true
+		c.Result = c.RenderText("ok")
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	return resp, invoked
+}
+
+func TestCORSFilter(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("cors.origins", "https://example.com, https://*.example.org, /^https://api[0-9]\\.example\\.net$/")
+	Config.SetOption("cors.expose", "X-Total")
+	defer func() {
+		Config.SetOption("cors.origins", "")
+		Config.SetOption("cors.expose", "")
+		initCORSPolicy()
+	}()
+	initCORSPolicy()
+
+	for _, origin := range []string{"https://example.com", "https://www.example.org", "https://api1.example.net"} {
+		resp, invoked := requestCORS("GET", "/hotels", map[string]string{"Origin": origin})
+		if !invoked || resp.Header().Get("Access-Control-Allow-Origin") != origin || resp.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+			t.Errorf("Expected the origin %s to be allowed, got %v", origin, resp.Header())
+		}
+	}
+	resp, invoked := requestCORS("GET", "/hotels", map[string]string{"Origin": "https://evil.com"})
+	if !invoked || resp.Header().Get("Access-Control-Allow-Origin") != "" || resp.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected no CORS headers for another origin, got %v", resp.Header())
+	}
+
+	// The preflight is answered without an OPTIONS route
+	resp, invoked = requestCORS("OPTIONS", "/hotels/1", map[string]string{
+		"Origin":                         "https://example.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "Content-Type",
+	})
+	if invoked || resp.Code != http.StatusNoContent || resp.Header().Get("Access-Control-Allow-Origin") != "https://example.com" ||
+		resp.Header().Get("Access-Control-Allow-Methods") == "" || resp.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected the preflight response, got %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = requestCORS("OPTIONS", "/hotels/1", map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"})
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected the preflight to be denied for another origin, got %d", resp.Code)
+	}
+}
+
+func TestCORSPolicyWildcardCredentials(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("cors.origins", "*")
+	Config.SetOption("cors.credentials", "true")
+	defer func() {
+		Config.SetOption("cors.origins", "")
+		Config.SetOption("cors.credentials", "false")
+		initCORSPolicy()
+	}()
+	initCORSPolicy()
+
+	if defaultCORSPolicy.Credentials {
+		t.Error("Expected the credentials to be disabled for any origin")
+	}
+	resp, invoked := requestCORS("GET", "/hotels", map[string]string{"Origin": "https://evil.com"})
+	if !invoked || resp.Header().Get("Access-Control-Allow-Origin") != "*" || resp.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected any origin without credentials, got %v", resp.Header())
+	}
+}
+
+func TestCORSFilterAction(t *testing.T) {
+	startFakeBookingApp()
+	initCORSPolicy()
+	FilterAction(Hotels.Show).CORS(CORSPolicy{
+		Origins:     []string{"https://example.com"},
+		Methods:     []string{"GET", "PUT"},
+		Headers:     []string{"*"},
+		Credentials: true,
+		MaxAge:      time.Hour,
+	})
+	defer delete(filterOptions, "Hotels.Show")
+
+	// The catch-all route accepts any method
+	resp, _ := requestCORS("OPTIONS", "/hotels/show", map[string]string{
+		"Origin":                         "https://example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "X-Custom",
+	})
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Access-Control-Allow-Origin") != "https://example.com" ||
+		resp.Header().Get("Access-Control-Allow-Credentials") != "true" || resp.Header().Get("Access-Control-Allow-Headers") != "X-Custom" ||
+		resp.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Errorf("Expected the preflight response of the action, got %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = requestCORS("OPTIONS", "/hotels/show", map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE"})
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected the method to be denied, got %d", resp.Code)
+	}
+
+	// The other actions keep the default policy, which allows no origin
+	if resp, _ = requestCORS("GET", "/hotels", map[string]string{"Origin": "https://example.com"}); resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers, got %v", resp.Header())
+	}
+}
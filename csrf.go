@@ -0,0 +1,201 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The filter option exempting an action from the CSRF check, see CSRFExempt.
+const CSRFExemptOption = "csrf.exempt"
+
+const (
+	// The session key holding the CSRF token.
+	csrfSessionKey = "_CSRF"
+	// The view argument holding the CSRF token.
+	csrfViewArg = "_csrf"
+)
+
+var (
+	csrfLog = RevelLog.New("section", "csrf")
+
+	// True to check the CSRF tokens, set via csrf.enabled
+	csrfEnabled bool
+	// True to keep the token in a cookie rather than the session, set via csrf.mode=cookie
+	csrfCookieMode bool
+	// The form field holding the token, set via csrf.field
+	csrfField = "csrf_token"
+	// The request header holding the token, set via csrf.header
+	csrfHeader = "X-CSRF-Token"
+)
+
+func init() {
+	OnAppStart(func() {
+		csrfEnabled = Config.BoolDefault("csrf.enabled", false)
+		csrfField = Config.StringDefault("csrf.field", "csrf_token")
+		csrfHeader = Config.StringDefault("csrf.header", "X-CSRF-Token")
+		switch mode := Config.StringDefault("csrf.mode", "session"); mode {
+		case "session":
+			csrfCookieMode = false
+		case "cookie":
+			csrfCookieMode = true
+		default:
+			csrfLog.Error("Unknown csrf.mode, expected session or cookie", "mode", mode)
+		}
+	})
+}
+
+// CSRFExempt exempts the controller or action from the CSRF check, e.g. for
+// a webhook authenticated by other means.
+//
+//	revel.FilterAction(Hooks.Payment).
+//		CSRFExempt()
+func (conf FilterConfigurator) CSRFExempt() FilterConfigurator {
+	return conf.SetOption(CSRFExemptOption, true)
+}
+
+// CSRFFilter protects the actions from cross-site request forgery, it is
+// enabled by csrf.enabled. Every visitor is given a random token, which the
+// requests with an unsafe method (all but GET, HEAD, OPTIONS and TRACE) must
+// send back in the csrf_token form field (csrf.field) or the X-CSRF-Token
+// header (csrf.header). Requests without a matching token are answered with
+// a 403. The actions streaming a multipart body read the form field from the
+// first part, see MultipartStream.
+//
+// The token is kept in the session by default. With csrf.mode=cookie it is
+// kept in a signed cookie readable by scripts, so stateless clients can copy
+// it into the header (double-submit cookie).
+//
+// Forms include the token with the csrfField template function:
+//
+//	<form method="POST" action="/hotels">
+//		{{csrfField .}}
+//		...
+//	</form>
+func CSRFFilter(c *Controller, fc []Filter) {
+	if !csrfEnabled {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	token, found := csrfRequestToken(c)
+	if !found {
+		token = newCSRFToken()
+		csrfStoreToken(c, token)
+	}
+	c.ViewArgs[csrfViewArg] = token
+
+	exempt := false
+	if value, found := c.FilterOption(CSRFExemptOption); found {
+		exempt, _ = value.(bool)
+	}
+	if !exempt && !csrfSafeMethod(c.Request.Method) {
+		submitted := c.Request.GetHttpHeader(csrfHeader)
+		if submitted == "" && streamsMultipart(c.MethodType) {
+			submitted = csrfStreamToken(c)
+		} else if submitted == "" && c.Params != nil {
+			submitted = c.Params.Form.Get(csrfField)
+		}
+		if !found || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			csrfLog.Warn("CSRFFilter: Invalid token", "action", c.Action, "submitted", submitted != "")
+			c.Response.Status = http.StatusForbidden
+			c.Result = c.RenderError(&Error{
+				Title:       "Forbidden",
+				Description: "The CSRF token is missing or invalid",
+			})
+			return
+		}
+	}
+
+	fc[0](c, fc[1:])
+}
+
+// CSRFToken returns the CSRF token of the visitor, e.g. to return it to a
+// client of a JSON API. It is empty unless the CSRFFilter is enabled.
+func CSRFToken(c *Controller) string {
+	token, _ := c.ViewArgs[csrfViewArg].(string)
+	return token
+}
+
+// Returns the token from the first part of a multipart body streamed to the
+// action, which leaves Params.Form empty. The part is consumed if it holds the
+// token.
+func csrfStreamToken(c *Controller) string {
+	stream := multipartStream(c)
+	part, err := stream.peek()
+	if err != nil || part.FormName() != csrfField || part.FileName() != "" {
+		return ""
+	}
+	stream.skip()
+	token, err := ioutil.ReadAll(io.LimitReader(part, 1024))
+	if err != nil {
+		return ""
+	}
+	return string(token)
+}
+
+// Returns the token of the visitor, if the request has one.
+func csrfRequestToken(c *Controller) (token string, found bool) {
+	if !csrfCookieMode {
+		token, found = c.Session[csrfSessionKey].(string)
+		return token, found && token != ""
+	}
+
+	cookie, err := c.Request.Cookie(CookiePrefix + "_CSRF")
+	if err != nil {
+		return "", false
+	}
+	// The cookie is signed, so it cannot be planted by a sibling domain
+	token = cookie.GetValue()
+	hyphen := strings.Index(token, "-")
+	if hyphen == -1 || !Verify(token[hyphen+1:], token[:hyphen]) {
+		return "", false
+	}
+	return token, true
+}
+
+// Stores the new token of the visitor.
+func csrfStoreToken(c *Controller, token string) {
+	if !csrfCookieMode {
+		c.Session[csrfSessionKey] = token
+		return
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     CookiePrefix + "_CSRF",
+		Value:    token,
+		Domain:   CookieDomain,
+		Path:     "/",
+		Secure:   CookieSecure,
+		SameSite: CookieSameSite,
+	})
+}
+
+// Returns a new random token, in cookie mode it is signed.
+func newCSRFToken() string {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		csrfLog.Error("newCSRFToken: Failed to generate token", "error", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	if csrfCookieMode {
+		token = Sign(token) + "-" + token
+	}
+	return token
+}
+
+// Returns true if the method does not change the state of the application.
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
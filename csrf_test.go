@@ -0,0 +1,167 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"bytes"
+	"html/template"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/revel/revel/session"
+)
+
+// Runs the request through the CSRFFilter, returning the response and true
+// if the action was invoked.
+func requestCSRF(req *http.Request, s session.Session) (*httptest.ResponseRecorder, bool) {
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	c.Action = "Hotels.Book"
+	c.Session = s
+	invoked := false
+	ParamsFilter(c, []Filter{CSRFFilter, func(c *Controller, _ []Filter) {
+		invoked = true
+		c.Result = c.RenderText(CSRFToken(c))
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	return resp, invoked
+}
+
+func newCSRFPost(token string, header bool) *http.Request {
+	form := url.Values{}
+	if !header {
+		form.Set("csrf_token", token)
+	}
+	req, _ := http.NewRequest("POST", "http://localhost/hotels/1/book", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if header {
+		req.Header.Set("X-CSRF-Token", token)
+	}
+	return req
+}
+
+func TestCSRFFilter(t *testing.T) {
+	startFakeBookingApp()
+	csrfEnabled = true
+	defer func() {
+		csrfEnabled = false
+		delete(filterOptions, "Hotels.Book")
+	}()
+	s := session.NewSession()
+
+	req, _ := http.NewRequest("GET", "http://localhost/hotels/1", nil)
+	resp, _ := requestCSRF(req, s)
+	token := resp.Body.String()
+	if token == "" || s[csrfSessionKey] != token {
+		t.Fatalf("Expected a token stored in the session, got %q", token)
+	}
+
+	for _, header := range []bool{false, true} {
+		if resp, invoked := requestCSRF(newCSRFPost(token, header), s); !invoked || resp.Body.String() != token {
+			t.Errorf("Expected the request with the token to be accepted, header %v", header)
+		}
+	}
+	if resp, invoked := requestCSRF(newCSRFPost("invalid", false), s); invoked || resp.Code != http.StatusForbidden {
+		t.Errorf("Expected a 403 for an invalid token, got %d", resp.Code)
+	}
+	if _, invoked := requestCSRF(newCSRFPost(token, false), session.NewSession()); invoked {
+		t.Error("Expected the token to be rejected for another session")
+	}
+
+	FilterAction(Hotels.Book).CSRFExempt()
+	if _, invoked := requestCSRF(newCSRFPost("", false), s); !invoked {
+		t.Error("Expected the exempt action to be invoked without a token")
+	}
+}
+
+func TestCSRFFilterCookie(t *testing.T) {
+	startFakeBookingApp()
+	csrfEnabled, csrfCookieMode = true, true
+	defer func() {
+		csrfEnabled, csrfCookieMode = false, false
+		_ = SetSecretKey(nil)
+	}()
+	_ = SetSecretKey([]byte("secret"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/hotels/1", nil)
+	resp, _ := requestCSRF(req, nil)
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != resp.Body.String() || cookies[0].HttpOnly {
+		t.Fatalf("Expected the token in a cookie readable by scripts, got %v", cookies)
+	}
+
+	req = newCSRFPost(cookies[0].Value, true)
+	req.AddCookie(cookies[0])
+	if _, invoked := requestCSRF(req, nil); !invoked {
+		t.Error("Expected the header matching the cookie to be accepted")
+	}
+
+	// A cookie planted without the signature is rejected
+	req = newCSRFPost("-planted", true)
+	req.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: "-planted"})
+	if resp, invoked := requestCSRF(req, nil); invoked || resp.Code != http.StatusForbidden {
+		t.Errorf("Expected a 403 for an unsigned cookie, got %d", resp.Code)
+	}
+}
+
+// Returns a multipart upload with the fields in order, then a file.
+func newCSRFUpload(fields ...string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for i := 0; i < len(fields); i += 2 {
+		_ = writer.WriteField(fields[i], fields[i+1])
+	}
+	file, _ := writer.CreateFormFile("file", "photo.jpg")
+	_, _ = file.Write([]byte("jpeg"))
+	_ = writer.Close()
+	req, _ := http.NewRequest("POST", "http://localhost/hotels/1/photos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCSRFFilterMultipartStream(t *testing.T) {
+	startFakeBookingApp()
+	csrfEnabled = true
+	defer func() { csrfEnabled = false }()
+	s := session.NewSession()
+	s[csrfSessionKey] = "token"
+
+	for _, test := range []struct {
+		fields   []string
+		accepted bool
+		first    string
+	}{
+		{[]string{"csrf_token", "token", "title", "Pool"}, true, "title"},
+		{[]string{"csrf_token", "token"}, true, "file"},
+		{[]string{"title", "Pool", "csrf_token", "token"}, false, ""},
+		{[]string{"csrf_token", "invalid"}, false, ""},
+	} {
+		c := NewTestController(httptest.NewRecorder(), newCSRFUpload(test.fields...))
+		c.Session = s
+		c.MethodType = &MethodType{Args: []*MethodArg{{Name: "upload", Type: multipartStreamType}}}
+		first := ""
+		ParamsFilter(c, []Filter{CSRFFilter, func(c *Controller, _ []Filter) {
+			// The stream of the action starts after the token
+			if part, err := multipartStream(c).Next(); err == nil {
+				first = part.FormName()
+			}
+			c.Result = c.RenderText("ok")
+		}})
+		if accepted := c.Response.Status != http.StatusForbidden; accepted != test.accepted || first != test.first {
+			t.Errorf("Fields %v: expected accepted %v and the first part %q, got %v and %q", test.fields, test.accepted, test.first, accepted, first)
+		}
+	}
+}
+
+func TestCSRFFieldTemplateFunc(t *testing.T) {
+	field := TemplateFuncs["csrfField"].(func(map[string]interface{}) template.HTML)
+	if html := field(map[string]interface{}{csrfViewArg: "a<b"}); html != `<input type="hidden" name="csrf_token" value="a&lt;b">` {
+		t.Errorf("Unexpected field %s", html)
+	}
+}
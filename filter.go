@@ -13,9 +13,11 @@ var Filters = []Filter{
 	PanicFilter,             // Recover from panics and display an error page instead.
 	RouterFilter,            // Use the routing table to select the right Action.
 	FilterConfiguringFilter, // A hook for adding or removing per-Action filters.
+	TimeoutFilter,           // Set the deadline for the action.
 	ParamsFilter,            // Parse parameters into Controller.Params.
 	SessionFilter,           // Restore and write the session cookie.
 	FlashFilter,             // Restore and write the flash cookie.
+	CSRFFilter,              // Check the CSRF token of unsafe requests, when csrf.enabled.
 	ValidationFilter,        // Restore kept validation errors and save new ones from cookie.
 	I18nFilter,              // Resolve the requested language.
 	InterceptorFilter,       // Run interceptors around the action.
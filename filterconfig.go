@@ -12,6 +12,9 @@ import (
 // Map from "Controller" or "Controller.Method" to the Filter chain.
 var filterOverrides = make(map[string][]Filter)
 
+// Map from "Controller" or "Controller.Method" to the options read by the filters.
+var filterOptions = make(map[string]map[string]interface{})
+
 // FilterConfigurator allows the developer configure the filter chain on a
 // per-controller or per-action basis.  The filter configuration is applied by
 // the FilterConfiguringFilter, which is itself a filter stage.  For example,
@@ -158,6 +161,21 @@ func (conf FilterConfigurator) insertFilter(insert Filter, where When, target Fi
 	return fc
 }
 
+// SetOption sets an option for the filters applied to the controller or
+// action, the filters read it with Controller.FilterOption. An option set on
+// the action takes precedence over the one set on the controller.
+//   revel.FilterAction(App.Report).
+//     SetOption("timeout", 2*time.Minute)
+func (conf FilterConfigurator) SetOption(name string, value interface{}) FilterConfigurator {
+	options, found := filterOptions[conf.key]
+	if !found {
+		options = map[string]interface{}{}
+		filterOptions[conf.key] = options
+	}
+	options[name] = value
+	return conf
+}
+
 // getChain returns the filter chain that applies to the given controller or
 // action.  If no overrides are configured, then a copy of the default filter
 // chain is returned.
@@ -205,8 +223,15 @@ func FilterEq(a, b Filter) bool {
 // filter chain for the action being invoked.
 func FilterConfiguringFilter(c *Controller, fc []Filter) {
 	if newChain := getOverrideChain(c.Name, c.Action); newChain != nil {
-		newChain[0](c, newChain[1:])
-		return
+		fc = newChain
+	}
+	// The filters of the route groups run just before the last stage, like
+	// the filters added by the configurator
+	if routeFilters, ok := c.Args[routeFiltersArg].([]Filter); ok && len(fc) > 0 {
+		chain := make([]Filter, 0, len(fc)+len(routeFilters))
+		chain = append(chain, fc[:len(fc)-1]...)
+		chain = append(chain, routeFilters...)
+		fc = append(chain, fc[len(fc)-1])
 	}
 	fc[0](c, fc[1:])
 }
@@ -221,3 +246,13 @@ func getOverrideChain(controllerName, action string) []Filter {
 	}
 	return nil
 }
+
+// FilterOption returns the option set by the FilterConfigurator for the action
+// being invoked, falling back to the option set for the controller.
+func (c *Controller) FilterOption(name string) (value interface{}, found bool) {
+	if value, found = filterOptions[c.Action][name]; found {
+		return
+	}
+	value, found = filterOptions[c.Name][name]
+	return
+}
@@ -139,3 +139,25 @@ func filterSliceEqual(a, e []Filter) bool {
 func getOverride(methodName string) []Filter {
 	return getOverrideChain("FakeController", "FakeController."+methodName)
 }
+
+func TestFilterConfiguratorOptions(t *testing.T) {
+	defer func() {
+		delete(filterOptions, "FakeController")
+		delete(filterOptions, "FakeController.Foo")
+	}()
+
+	FilterController(FakeController{}).SetOption("name", "controller")
+	FilterAction(FakeController.Foo).SetOption("name", "action")
+
+	c := &Controller{Name: "FakeController", Action: "FakeController.Foo"}
+	if value, found := c.FilterOption("name"); !found || value != "action" {
+		t.Errorf("Expected the action option, was %v", value)
+	}
+	c.Action = "FakeController.Bar"
+	if value, found := c.FilterOption("name"); !found || value != "controller" {
+		t.Errorf("Expected the controller option, was %v", value)
+	}
+	if _, found := c.FilterOption("missing"); found {
+		t.Errorf("Expected no option to be found")
+	}
+}
@@ -42,7 +42,8 @@ func (f Flash) Success(msg string, args ...interface{}) {
 
 // FlashFilter is a Revel Filter that retrieves and sets the flash cookie.
 // Within Revel, it is available as a Flash attribute on Controller instances.
-// The name of the Flash cookie is set as CookiePrefix + "_FLASH".
+// The name of the Flash cookie is set as CookiePrefix + "_FLASH", the cookie
+// is encrypted when cookie.encrypt is set.
 func FlashFilter(c *Controller, fc []Filter) {
 	c.Flash = restoreFlash(c.Request)
 	c.ViewArgs["flash"] = c.Flash.Data
@@ -54,9 +55,13 @@ func FlashFilter(c *Controller, fc []Filter) {
 	for key, value := range c.Flash.Out {
 		flashValue += "\x00" + key + ":" + value + "\x00"
 	}
+	flashValue = url.QueryEscape(flashValue)
+	if CookieEncrypt && flashValue != "" {
+		flashValue = sealCookieValue(CookiePrefix+"_FLASH", flashValue)
+	}
 	c.SetCookie(&http.Cookie{
 		Name:     CookiePrefix + "_FLASH",
-		Value:    url.QueryEscape(flashValue),
+		Value:    flashValue,
 		HttpOnly: true,
 		Secure:   CookieSecure,
 		SameSite: CookieSameSite,
@@ -71,7 +76,14 @@ func restoreFlash(req *Request) Flash {
 		Out:  make(map[string]string),
 	}
 	if cookie, err := req.Cookie(CookiePrefix + "_FLASH"); err == nil {
-		ParseKeyValueCookie(cookie.GetValue(), func(key, val string) {
+		value := cookie.GetValue()
+		if CookieEncrypt && value != "" {
+			if value, err = Decrypt(value, CookiePrefix+"_FLASH"); err != nil {
+				// A flash from before the cookies were encrypted, or altered
+				return flash
+			}
+		}
+		ParseKeyValueCookie(value, func(key, val string) {
 			flash.Data[key] = val
 		})
 	}
@@ -0,0 +1,45 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlashFilterEncrypted(t *testing.T) {
+	CookieEncrypt = true
+	defer func() {
+		CookieEncrypt = false
+		_ = SetSecretKey(nil)
+	}()
+	_ = SetSecretKey([]byte("secret"))
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://localhost/hotels", nil)
+	c := NewTestController(resp, req)
+	FlashFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		c.Flash.Success("Booked")
+	}})
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" || strings.Contains(cookies[0].Value, "Booked") {
+		t.Fatalf("Expected an encrypted flash cookie, got %v", cookies)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost/hotels", nil)
+	req.AddCookie(cookies[0])
+	if flash := restoreFlash(NewTestController(httptest.NewRecorder(), req).Request); flash.Data["success"] != "Booked" {
+		t.Errorf("Expected the flash from the cookie, got %v", flash.Data)
+	}
+
+	// An altered cookie is ignored
+	req, _ = http.NewRequest("GET", "http://localhost/hotels", nil)
+	req.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: alterSealed(cookies[0].Value)})
+	if flash := restoreFlash(NewTestController(httptest.NewRecorder(), req).Request); len(flash.Data) != 0 {
+		t.Errorf("Expected no flash from an altered cookie, got %v", flash.Data)
+	}
+}
@@ -3,11 +3,13 @@ module github.com/revel/revel
 go 1.17
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/bradfitz/gomemcache v0.0.0-20220106215444-fb4bf637b56d
 	github.com/fsnotify/fsnotify v1.5.1
 	github.com/go-stack/stack v1.8.1
 	github.com/gomodule/redigo v1.8.8
 	github.com/google/uuid v1.3.0
+	github.com/klauspost/compress v1.15.1
 	github.com/mattn/go-colorable v0.1.12
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/revel/config v1.0.0
@@ -27,6 +29,7 @@ require (
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
+	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -36,8 +37,9 @@ type Request struct {
 	// DEPRECATED use GetForm()
 	Form url.Values // The Form
 	// DEPRECATED use GetMultipartForm()
-	MultipartForm *MultipartForm // The multipart form
-	controller    *Controller    // The controller, so some of this data can be fetched
+	MultipartForm *MultipartForm  // The multipart form
+	controller    *Controller     // The controller, so some of this data can be fetched
+	ctx           context.Context // The context replacing the one from the server request, set by SetContext
 }
 
 var (
@@ -131,10 +133,22 @@ func (req *Request) GetBody() (body io.Reader) {
 
 // Fetch the context.
 func (req *Request) Context() (c context.Context) {
+	if req.ctx != nil {
+		return req.ctx
+	}
 	c, _ = req.GetValue(HTTP_REQUEST_CONTEXT).(context.Context)
 	return
 }
 
+// SetContext replaces the context of the request, the server request is
+// updated as well when the engine supports it.
+func (req *Request) SetContext(c context.Context) {
+	req.ctx = c
+	if req.In != nil {
+		req.In.Set(HTTP_REQUEST_CONTEXT, c)
+	}
+}
+
 // Deprecated use controller.Params.Get().
 func (req *Request) FormValue(key string) (value string) {
 	return req.controller.Params.Get(key)
@@ -174,8 +188,22 @@ type MultipartForm struct {
 	origin ServerMultipartForm
 }
 
+// MultipartReader returns a reader for the parts of a multipart/form-data or
+// multipart/mixed body, use it instead of the parsed form to stream the body.
 func (req *Request) MultipartReader() (*multipart.Reader, error) {
-	return nil, errors.New("MultipartReader not supported, use controller.Param")
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "multipart/form-data" && mediaType != "multipart/mixed") {
+		return nil, http.ErrNotMultipart
+	}
+	boundary, found := params["boundary"]
+	if !found {
+		return nil, http.ErrMissingBoundary
+	}
+	body := req.GetBody()
+	if body == nil {
+		return nil, errors.New("MultipartReader no request body found")
+	}
+	return multipart.NewReader(body, boundary), nil
 }
 
 // Deprecated for backwards compatibility only.
@@ -220,6 +248,7 @@ func (req *Request) Destroy() {
 	req.URL = nil
 	req.Form = nil
 	req.MultipartForm = nil
+	req.ctx = nil
 }
 
 // Set the server response.
@@ -25,6 +25,9 @@ func ActionInvoker(c *Controller, _ []Filter) {
 		var boundArg reflect.Value
 		if arg.Type.Implements(websocketType) {
 			boundArg = reflect.ValueOf(c.Request.WebSocket)
+		} else if arg.Type == multipartStreamType {
+			// Multipart bodies are streamed to the action, see MultipartStream
+			boundArg = reflect.ValueOf(multipartStream(c))
 		} else {
 			boundArg = Bind(c.Params, arg.Name, arg.Type)
 			// #756 - If the argument is a closer, defer a Close call,
@@ -5,22 +5,185 @@
 package revel
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"hash"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Sign a given string with the app-configured secret key.
 // If no secret key is set, returns the empty string.
-// Return the signature in base64 (URLEncoding).
+// Return the signature in hex, prefixed with the id of the key and a dot so
+// Verify can check it once the key has been rotated. The HMAC hash is set by
+// app.secret.algorithm (sha1 or sha256).
 func Sign(message string) string {
 	if len(secretKey) == 0 {
 		return ""
 	}
-	mac := hmac.New(sha1.New, secretKey)
+	return secretKeyID(secretKey) + "." + signWith(secretKey, signatureHash, message)
+}
+
+// Verify returns true if the given signature is correct for the given message.
+// e.g. it matches what we generate with Sign(). Signatures made with one of the
+// previous secret keys (app.secret.previous) are accepted. The hash is told by
+// the length of the signature, the signatures made with the hashes listed in
+// app.secret.algorithm.previous are accepted while the algorithm is changed.
+// The signatures without a key id, made with SHA1 before they were introduced,
+// are accepted as long as sha1 is the algorithm or a previous one.
+func Verify(message, sig string) bool {
+	if len(secretKey) == 0 {
+		return sig == ""
+	}
+	dot := strings.Index(sig, ".")
+	if dot == -1 {
+		if !legacySignatures {
+			return false
+		}
+		// A signature made with SHA1, before the key ids
+		for _, key := range secretKeys() {
+			if hmac.Equal([]byte(sig), []byte(signWith(key, sha1.New, message))) {
+				return true
+			}
+		}
+		return false
+	}
+	keyID, mac := sig[:dot], sig[dot+1:]
+	for _, key := range secretKeys() {
+		if secretKeyID(key) == keyID {
+			h := signatureHashOf(mac)
+			return h != nil && hmac.Equal([]byte(mac), []byte(signWith(key, h, message)))
+		}
+	}
+	return false
+}
+
+var (
+	// ErrTokenInvalid is returned by VerifyToken when the token is malformed
+	// or its signature does not match.
+	ErrTokenInvalid = errors.New("revel: invalid signed token")
+	// ErrTokenExpired is returned by VerifyToken when the token has expired.
+	ErrTokenExpired = errors.New("revel: signed token expired")
+)
+
+// SignToken returns a token holding the value, which VerifyToken accepts
+// until the ttl has passed. The value is readable by the client, it is only
+// protected from being altered. For example, to build an unsubscribe link:
+//
+//	token, err := revel.SignToken(user.Email, 7*24*time.Hour)
+//
+// Or in a template:
+//
+//	<a href="/unsubscribe?token={{signToken .user.Email "168h"}}">Unsubscribe</a>
+//
+// Returns ErrNoSecretKey if no secret key is set, as the token could not be
+// told from a forged one.
+func SignToken(value string, ttl time.Duration) (string, error) {
+	if len(secretKey) == 0 {
+		return "", ErrNoSecretKey
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return payload + "." + Sign(payload), nil
+}
+
+// VerifyToken returns the value of a token made by SignToken, or an error if
+// the token is invalid or has expired. Every token is invalid if no secret key
+// is set.
+func VerifyToken(token string) (value string, err error) {
+	if len(secretKey) == 0 {
+		return "", ErrTokenInvalid
+	}
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 {
+		return "", ErrTokenInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !Verify(payload, parts[2]) {
+		return "", ErrTokenInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	if time.Now().Unix() > expires {
+		return "", ErrTokenExpired
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	return string(decoded), nil
+}
+
+var (
+	// The hash functions selectable by app.secret.algorithm.
+	signatureHashes = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+	}
+	// The hash of the signatures.
+	signatureHash = sha1.New
+	// The hashes of the signatures still accepted, see SetSignatureAlgorithm.
+	previousSignatureHashes []func() hash.Hash
+	// True if the SHA1 signatures without key id are accepted.
+	legacySignatures = true
+)
+
+// Set the hash of the signatures (sha1 or sha256), and the hashes used before
+// it, so the signatures made with them are still accepted while the algorithm
+// is changed. The signatures made with any other hash are rejected.
+func SetSignatureAlgorithm(algorithm string, previous ...string) error {
+	hashes := []func() hash.Hash{}
+	legacy := false
+	for _, name := range append([]string{algorithm}, previous...) {
+		h := signatureHashes[name]
+		if h == nil {
+			return errors.New("revel: unknown signature algorithm " + name)
+		}
+		hashes = append(hashes, h)
+		legacy = legacy || name == "sha1"
+	}
+	signatureHash, previousSignatureHashes, legacySignatures = hashes[0], hashes[1:], legacy
+	return nil
+}
+
+// Returns the secret key followed by the previous secret keys.
+func secretKeys() [][]byte {
+	return append([][]byte{secretKey}, previousKeys...)
+}
+
+// Returns the hash of a signature from the length of its hex MAC, the hash
+// of app.secret.algorithm first. Returns nil if no accepted hash matches.
+func signatureHashOf(mac string) func() hash.Hash {
+	if len(mac) == hex.EncodedLen(signatureHash().Size()) {
+		return signatureHash
+	}
+	for _, h := range previousSignatureHashes {
+		if len(mac) == hex.EncodedLen(h().Size()) {
+			return h
+		}
+	}
+	return nil
+}
+
+// Returns the id identifying the key in the signatures.
+func secretKeyID(key []byte) string {
+	return signWith(key, sha256.New, "revel key id")[:8]
+}
+
+// Returns the HMAC of the message in hex.
+func signWith(key []byte, h func() hash.Hash, message string) string {
+	mac := hmac.New(h, key)
 	if _, err := io.WriteString(mac, message); err != nil {
 		utilLog.Error("WriteString failed", "error", err)
 		return ""
@@ -28,10 +191,71 @@ func Sign(message string) string {
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// Verify returns true if the given signature is correct for the given message.
-// e.g. it matches what we generate with Sign().
-func Verify(message, sig string) bool {
-	return hmac.Equal([]byte(sig), []byte(Sign(message)))
+var (
+	// ErrNoSecretKey is returned by Encrypt and SignToken when no secret key
+	// is set.
+	ErrNoSecretKey = errors.New("revel: no secret key")
+	// ErrDecrypt is returned by Decrypt when the message was not sealed by
+	// Encrypt with any of the secret keys, or has been altered.
+	ErrDecrypt = errors.New("revel: message authentication failed")
+)
+
+// Encrypt seals the message with AES-256-GCM, using a key derived from the
+// app-configured secret key. The additional data (e.g. the cookie name) is
+// authenticated but not encrypted, Decrypt must be given the same.
+// Returns the nonce and ciphertext in base64 (RawURLEncoding).
+func Encrypt(message, additionalData string) (string, error) {
+	if len(secretKey) == 0 {
+		return "", ErrNoSecretKey
+	}
+	aead, err := newCookieAEAD(secretKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(message)+aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(message), []byte(additionalData))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a message sealed by Encrypt with the secret key, or with one
+// of the previous secret keys (app.secret.previous) while the key is rotated.
+func Decrypt(sealed, additionalData string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	for _, key := range secretKeys() {
+		if len(key) == 0 {
+			continue
+		}
+		aead, err := newCookieAEAD(key)
+		if err != nil {
+			return "", err
+		}
+		if len(data) < aead.NonceSize() {
+			return "", ErrDecrypt
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if message, err := aead.Open(nil, nonce, ciphertext, []byte(additionalData)); err == nil {
+			return string(message), nil
+		}
+	}
+	return "", ErrDecrypt
+}
+
+// Returns the AES-GCM cipher for the secret, the encryption key is derived
+// from the secret so it differs from the signing key.
+func newCookieAEAD(secret []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = io.WriteString(mac, "revel cookie encryption")
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
 
 // ToBool method converts/assert value into true or false. Default is true.
@@ -4,7 +4,15 @@
 
 package revel
 
-import "testing"
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestToBooleanForFalse(t *testing.T) {
 	if ToBool(nil) ||
@@ -36,3 +44,143 @@ func TestToBooleanForTrue(t *testing.T) {
 		t.Error("Expected 'true' got 'false'")
 	}
 }
+
+func TestEncrypt(t *testing.T) {
+	defer func() {
+		_ = SetSecretKey(nil)
+		SetPreviousSecretKeys()
+	}()
+	_ = SetSecretKey(nil)
+	if _, err := Encrypt("message", "name"); err != ErrNoSecretKey {
+		t.Errorf("Expected ErrNoSecretKey, got %v", err)
+	}
+
+	_ = SetSecretKey([]byte("old"))
+	sealed, err := Encrypt("message", "name")
+	if err != nil || sealed == "" {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if strings.Contains(sealed, "message") {
+		t.Errorf("Expected the message to be encrypted, got %q", sealed)
+	}
+	if message, err := Decrypt(sealed, "name"); err != nil || message != "message" {
+		t.Errorf("Expected the message, got %q %v", message, err)
+	}
+	if _, err := Decrypt(sealed, "other"); err != ErrDecrypt {
+		t.Errorf("Expected the additional data to be authenticated, got %v", err)
+	}
+	if _, err := Decrypt(alterSealed(sealed), "name"); err != ErrDecrypt {
+		t.Errorf("Expected an altered message to fail, got %v", err)
+	}
+
+	// The key is rotated
+	_ = SetSecretKey([]byte("new"))
+	if _, err := Decrypt(sealed, "name"); err != ErrDecrypt {
+		t.Errorf("Expected the old key to be rejected, got %v", err)
+	}
+	SetPreviousSecretKeys([]byte("old"))
+	if message, err := Decrypt(sealed, "name"); err != nil || message != "message" {
+		t.Errorf("Expected the message with the previous key, got %q %v", message, err)
+	}
+}
+
+// Returns the sealed message with its first character replaced.
+func alterSealed(sealed string) string {
+	if sealed[0] == 'A' {
+		return "B" + sealed[1:]
+	}
+	return "A" + sealed[1:]
+}
+
+func TestSignKeyRotation(t *testing.T) {
+	defer func() {
+		_ = SetSecretKey(nil)
+		SetPreviousSecretKeys()
+		_ = SetSignatureAlgorithm("sha1")
+	}()
+	_ = SetSecretKey([]byte("old"))
+	_ = SetSignatureAlgorithm("sha256", "sha1")
+	sig := Sign("message")
+	if !strings.HasPrefix(sig, secretKeyID([]byte("old"))+".") || len(sig) != 8+1+64 {
+		t.Errorf("Expected a SHA256 signature with the key id, got %q", sig)
+	}
+	if !Verify("message", sig) || Verify("other", sig) {
+		t.Error("Expected the signature to match the message only")
+	}
+
+	// A signature made before the key ids
+	mac := hmac.New(sha1.New, []byte("old"))
+	mac.Write([]byte("message"))
+	legacySig := hex.EncodeToString(mac.Sum(nil))
+	if !Verify("message", legacySig) {
+		t.Error("Expected a signature without key id to be accepted while sha1 is a previous algorithm")
+	}
+	_ = SetSignatureAlgorithm("sha256")
+	if Verify("message", legacySig) {
+		t.Error("Expected a signature without key id to be rejected once sha1 is retired")
+	}
+
+	// The key is rotated
+	_ = SetSecretKey([]byte("new"))
+	if Verify("message", sig) {
+		t.Error("Expected the old key to be rejected")
+	}
+	SetPreviousSecretKeys([]byte("old"))
+	if !Verify("message", sig) {
+		t.Error("Expected the signature with the previous key to be accepted")
+	}
+	if newSig := Sign("message"); newSig == sig || !Verify("message", newSig) {
+		t.Errorf("Expected a signature with the new key, got %q", newSig)
+	}
+
+	// The algorithm is changed back
+	_ = SetSignatureAlgorithm("sha1")
+	if Verify("message", sig) {
+		t.Error("Expected the signature made with a retired algorithm to be rejected")
+	}
+	_ = SetSignatureAlgorithm("sha1", "sha256")
+	if !Verify("message", sig) || Verify("other", sig) {
+		t.Error("Expected the signature made with the previous algorithm to be accepted")
+	}
+	if newSig := Sign("message"); len(newSig) != 8+1+40 || !Verify("message", newSig) {
+		t.Errorf("Expected a SHA1 signature, got %q", newSig)
+	}
+	if err := SetSignatureAlgorithm("md5"); err == nil {
+		t.Error("Expected an unknown algorithm to be refused")
+	}
+}
+
+func TestSignToken(t *testing.T) {
+	defer func() {
+		_ = SetSecretKey(nil)
+	}()
+	_ = SetSecretKey([]byte("secret"))
+
+	token, err := SignToken("alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("SignToken failed: %v", err)
+	}
+	if value, err := VerifyToken(token); err != nil || value != "alice@example.com" {
+		t.Errorf("Expected the value of the token, got %q %v", value, err)
+	}
+	if _, err := VerifyToken(alterSealed(token)); err != ErrTokenInvalid {
+		t.Errorf("Expected an altered token to be invalid, got %v", err)
+	}
+	if _, err := VerifyToken("token"); err != ErrTokenInvalid {
+		t.Errorf("Expected a malformed token to be invalid, got %v", err)
+	}
+	expired, _ := SignToken("alice@example.com", -time.Minute)
+	if _, err := VerifyToken(expired); err != ErrTokenExpired {
+		t.Errorf("Expected the token to be expired, got %v", err)
+	}
+
+	// Without a key no token is signed, nor accepted
+	_ = SetSecretKey(nil)
+	if _, err := SignToken("alice@example.com", time.Hour); err != ErrNoSecretKey {
+		t.Errorf("Expected ErrNoSecretKey, got %v", err)
+	}
+	forged := base64.RawURLEncoding.EncodeToString([]byte("alice@example.com")) + ".9999999999."
+	if _, err := VerifyToken(forged); err != ErrTokenInvalid {
+		t.Errorf("Expected an unsigned token to be invalid, got %v", err)
+	}
+}
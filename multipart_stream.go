@@ -0,0 +1,163 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"errors"
+	"mime/multipart"
+	"reflect"
+)
+
+// The controller argument holding the stream opened before the action.
+const multipartStreamArg = "multipart.stream"
+
+var (
+	multipartStreamType = reflect.TypeOf((*MultipartStream)(nil))
+
+	// Returned when a part exceeds the MultipartStream.MaxPartSize.
+	ErrPartTooLarge = errors.New("Multipart part too large")
+	// Returned when the request has more parts than the MultipartStream.MaxParts.
+	ErrTooManyParts = errors.New("Multipart request has too many parts")
+)
+
+// MultipartStream iterates over the parts of a multipart request body as they
+// are received, nothing is buffered in memory or written to temporary files.
+// When an action declares a *MultipartStream argument the ParamsFilter leaves
+// the multipart body unparsed, so Params.Form and Params.Files are empty and
+// the form values have to be read from the stream. For example:
+//
+//     func (c Media) Upload(upload *revel.MultipartStream) revel.Result {
+//     	 for {
+//     	 	part, err := upload.Next()
+//     	 	if err == io.EOF {
+//     	 		break
+//     	 	} else if err != nil {
+//     	 		return c.RenderError(err)
+//     	 	}
+//     	 	if part.FileName() != "" {
+//     	 		// A part larger than MaxPartSize fails with ErrPartTooLarge
+//     	 		if _, err = store.Put(part.FileName(), part); err != nil {
+//     	 			return c.RenderError(err)
+//     	 		}
+//     	 	}
+//     	 }
+//     	 return c.RenderText("ok")
+//     }
+//
+// The CSRFFilter reads the token of a streamed form from its first part, so
+// the csrfField has to come before the other fields of the form. The stream
+// starts after the token.
+type MultipartStream struct {
+	MaxPartSize int64 // The largest part read, set via server.request.max.multipart.partsize (in MB), 0 for no limit
+	MaxParts    int   // The most parts read, set via server.request.max.multipart.parts, 0 for no limit
+	reader      *multipart.Reader
+	err         error          // The error creating the reader
+	parts       int            // The number of parts returned
+	peeked      *MultipartPart // The part returned by peek, returned next
+}
+
+// MultipartPart is a single part of the MultipartStream, reading the part
+// fails with ErrPartTooLarge once more than the maximum part size is read.
+type MultipartPart struct {
+	*multipart.Part
+	remaining int64 // The bytes left to read before the part is too large, negative for no limit
+}
+
+// NewMultipartStream returns the stream for the multipart body of the request,
+// if the request is not a multipart request the error is returned by Next.
+func NewMultipartStream(req *Request) *MultipartStream {
+	stream := &MultipartStream{MaxParts: 1000}
+	if Config != nil {
+		stream.MaxPartSize = int64(Config.IntDefault("server.request.max.multipart.partsize", 0)) << 20
+		stream.MaxParts = Config.IntDefault("server.request.max.multipart.parts", stream.MaxParts)
+	}
+	stream.reader, stream.err = req.MultipartReader()
+	return stream
+}
+
+// Next returns the next part of the body, io.EOF is returned after the last
+// part. Any unread data of the previous part is discarded.
+func (s *MultipartStream) Next() (*MultipartPart, error) {
+	if part := s.peeked; part != nil {
+		s.peeked = nil
+		return part, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.MaxParts > 0 && s.parts >= s.MaxParts {
+		return nil, ErrTooManyParts
+	}
+
+	part, err := s.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	s.parts++
+	remaining := int64(-1)
+	if s.MaxPartSize > 0 {
+		remaining = s.MaxPartSize
+	}
+	return &MultipartPart{Part: part, remaining: remaining}, nil
+}
+
+// Read reads the content of the part.
+func (p *MultipartPart) Read(b []byte) (n int, err error) {
+	if p.remaining < 0 {
+		return p.Part.Read(b)
+	}
+	// Read one byte past the limit to detect a part that is too large
+	if int64(len(b)) > p.remaining+1 {
+		b = b[:p.remaining+1]
+	}
+	n, err = p.Part.Read(b)
+	if int64(n) > p.remaining {
+		n, err = int(p.remaining), ErrPartTooLarge
+	}
+	p.remaining -= int64(n)
+	return
+}
+
+// Returns the next part without consuming it, Next returns it again unless
+// skip is called.
+func (s *MultipartStream) peek() (*MultipartPart, error) {
+	if s.peeked == nil {
+		part, err := s.Next()
+		if err != nil {
+			return nil, err
+		}
+		s.peeked = part
+	}
+	return s.peeked, nil
+}
+
+// Consumes the part returned by peek.
+func (s *MultipartStream) skip() {
+	s.peeked = nil
+}
+
+// Returns the stream of the request body for the action, the same stream is
+// returned to the filters and the ActionInvoker.
+func multipartStream(c *Controller) *MultipartStream {
+	if stream, ok := c.Args[multipartStreamArg].(*MultipartStream); ok {
+		return stream
+	}
+	stream := NewMultipartStream(c.Request)
+	c.Args[multipartStreamArg] = stream
+	return stream
+}
+
+// Returns true if the action streams the multipart body.
+func streamsMultipart(methodType *MethodType) bool {
+	if methodType == nil {
+		return false
+	}
+	for _, arg := range methodType.Args {
+		if arg.Type == multipartStreamType {
+			return true
+		}
+	}
+	return false
+}
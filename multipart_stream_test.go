@@ -0,0 +1,74 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipartStream(t *testing.T) {
+	a := assert.New(t)
+	c := NewTestController(nil, getMultipartRequest())
+	c.MethodType = &MethodType{Args: []*MethodArg{{Name: "upload", Type: multipartStreamType}}}
+
+	// The body is left for the action to stream
+	ParamsFilter(c, NilChain)
+	a.Len(c.Params.Form, 0)
+	a.Len(c.Params.Files, 0)
+
+	stream := NewMultipartStream(c.Request)
+	var names []string
+	for {
+		part, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		a.Nil(err)
+		content, err := ioutil.ReadAll(part)
+		a.Nil(err)
+		names = append(names, part.FormName())
+		if part.FormName() == "file2[]" && part.FileName() == "favicon.ico" {
+			a.Equal("xyz", string(content))
+		}
+	}
+	a.Equal([]string{"text1", "text2", "text2", "file1", "file2[]", "file2[]", "file3[0]", "file3[1]"}, names)
+}
+
+func TestMultipartStreamLimits(t *testing.T) {
+	a := assert.New(t)
+	c := NewTestController(nil, getMultipartRequest())
+
+	stream := NewMultipartStream(c.Request)
+	stream.MaxPartSize = 5
+	stream.MaxParts = 2
+	part, err := stream.Next()
+	a.Nil(err)
+	content, err := ioutil.ReadAll(part)
+	a.Nil(err, "A part at the limit can be read")
+	a.Equal("data1", string(content))
+
+	part, err = stream.Next()
+	a.Nil(err)
+	_, err = ioutil.ReadAll(part)
+	a.Nil(err)
+
+	_, err = stream.Next()
+	a.Equal(ErrTooManyParts, err)
+
+	stream = NewMultipartStream(NewTestController(nil, getMultipartRequest()).Request)
+	stream.MaxPartSize = 4
+	part, err = stream.Next()
+	a.Nil(err)
+	_, err = ioutil.ReadAll(part)
+	a.Equal(ErrPartTooLarge, err)
+
+	stream = NewMultipartStream(NewTestController(nil, getBodyRequest("application/json", "{}")).Request)
+	_, err = stream.Next()
+	a.NotNil(err, "A request which is not multipart cannot be streamed")
+}
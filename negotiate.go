@@ -0,0 +1,283 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptRange is a single media range from the Accept HTTP header.
+type AcceptRange struct {
+	Type    string  // The type, e.g. "application" or "*"
+	Subtype string  // The subtype, e.g. "json" or "*"
+	Quality float32 // The quality, 0 means not acceptable
+}
+
+// AcceptRanges is a collection of AcceptRange instances, sorted by quality.
+type AcceptRanges []AcceptRange
+
+func (ar AcceptRanges) Len() int           { return len(ar) }
+func (ar AcceptRanges) Swap(i, j int)      { ar[i], ar[j] = ar[j], ar[i] }
+func (ar AcceptRanges) Less(i, j int) bool { return ar[i].Quality > ar[j].Quality }
+
+// Returns true if the range matches the mime type.
+func (r AcceptRange) matches(mimeType string) bool {
+	typ, subtype := splitMimeType(mimeType)
+	return (r.Type == "*" || r.Type == typ) && (r.Subtype == "*" || r.Subtype == subtype)
+}
+
+// Returns the specificity of the range, a more specific range takes precedence.
+func (r AcceptRange) specificity() (s int) {
+	if r.Type != "*" {
+		s++
+	}
+	if r.Subtype != "*" {
+		s++
+	}
+	return
+}
+
+// Quality returns the quality the ranges assign to the mime type, the most
+// specific matching range decides. Zero is returned when no range matches.
+func (ar AcceptRanges) Quality(mimeType string) (quality float32) {
+	best := -1
+	for _, r := range ar {
+		if r.matches(mimeType) && r.specificity() > best {
+			best, quality = r.specificity(), r.Quality
+		}
+	}
+	return
+}
+
+// ParseAccept parses an Accept (or similarly formatted) header into the media
+// ranges, sorted by quality with the most qualified range first. Ranges with
+// the same quality retain their order in the header.
+func ParseAccept(header string) AcceptRanges {
+	var ranges AcceptRanges
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mimeType == "" {
+			continue
+		}
+		if mimeType == "*" {
+			mimeType = "*/*"
+		}
+		r := AcceptRange{Quality: 1}
+		r.Type, r.Subtype = splitMimeType(mimeType)
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if quality, err := strconv.ParseFloat(param[2:], 32); err != nil {
+				httpLog.Warn("Detected malformed Accept header quality, assuming quality is 1", "range", part)
+			} else {
+				r.Quality = float32(quality)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	sort.Stable(ranges)
+	return ranges
+}
+
+// Splits the mime type into the type and the subtype, parameters are dropped.
+func splitMimeType(mimeType string) (typ, subtype string) {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(mimeType)), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], "*"
+	}
+	return parts[0], parts[1]
+}
+
+// ResultEncoder encodes the object rendered by Controller.RenderNegotiated in
+// a format. An encoder without an Encode function renders the action template
+// for the format instead.
+type ResultEncoder struct {
+	Format      string                                   // The format, matched against the extension of the path, e.g. "json"
+	ContentType string                                   // The content type written, e.g. "application/json; charset=utf-8"
+	MimeTypes   []string                                 // The mime types in the Accept header served by this encoder
+	Encode      func(w io.Writer, obj interface{}) error // Encodes the object
+}
+
+var (
+	// The registered encoders by format.
+	resultEncoders = map[string]*ResultEncoder{}
+	// The registered formats, in the order they are offered when the client has no preference.
+	resultEncoderFormats []string
+)
+
+func init() {
+	RegisterResultEncoder(&ResultEncoder{
+		Format:      "html",
+		ContentType: "text/html; charset=utf-8",
+		MimeTypes:   []string{"text/html", "application/xhtml+xml"},
+	})
+	RegisterResultEncoder(&ResultEncoder{
+		Format:      "json",
+		ContentType: "application/json; charset=utf-8",
+		MimeTypes:   []string{"application/json", "text/javascript", "application/javascript"},
+		Encode: func(w io.Writer, obj interface{}) error {
+			encoder := json.NewEncoder(w)
+			if Config.BoolDefault("results.pretty", false) {
+				encoder.SetIndent("", "  ")
+			}
+			return encoder.Encode(obj)
+		},
+	})
+	RegisterResultEncoder(&ResultEncoder{
+		Format:      "xml",
+		ContentType: "application/xml; charset=utf-8",
+		MimeTypes:   []string{"application/xml", "text/xml"},
+		Encode: func(w io.Writer, obj interface{}) error {
+			encoder := xml.NewEncoder(w)
+			if Config.BoolDefault("results.pretty", false) {
+				encoder.Indent("", "  ")
+			}
+			return encoder.Encode(obj)
+		},
+	})
+	RegisterResultEncoder(&ResultEncoder{
+		Format:      "txt",
+		ContentType: "text/plain; charset=utf-8",
+		MimeTypes:   []string{"text/plain"},
+		Encode: func(w io.Writer, obj interface{}) (err error) {
+			_, err = fmt.Fprint(w, obj)
+			return
+		},
+	})
+}
+
+// RegisterResultEncoder registers an encoder for Controller.RenderNegotiated,
+// an encoder registered for an existing format replaces it. For example:
+//   revel.RegisterResultEncoder(&revel.ResultEncoder{
+//     Format:      "yaml",
+//     ContentType: "application/yaml",
+//     MimeTypes:   []string{"application/yaml", "application/x-yaml", "text/yaml"},
+//     Encode: func(w io.Writer, obj interface{}) error {
+//       return yaml.NewEncoder(w).Encode(obj)
+//     },
+//   })
+func RegisterResultEncoder(encoder *ResultEncoder) {
+	if _, found := resultEncoders[encoder.Format]; !found {
+		resultEncoderFormats = append(resultEncoderFormats, encoder.Format)
+	}
+	resultEncoders[encoder.Format] = encoder
+}
+
+// NegotiateFormat returns the format of the offered formats (all the
+// registered formats when none are offered) which best matches the request.
+// An extension on the path (e.g. /hotels/3.json) selects the format, otherwise
+// the quality values of the Accept header decide, ties are broken by the order
+// of the offered formats. An empty string is returned when nothing matches.
+func NegotiateFormat(req *Request, formats ...string) string {
+	if len(formats) == 0 {
+		formats = resultEncoderFormats
+	}
+
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(req.GetPath())), "."); ext != "" {
+		for _, format := range formats {
+			if format == ext {
+				return format
+			}
+		}
+	}
+
+	header := req.GetHttpHeader("accept")
+	if header == "" {
+		return formats[0]
+	}
+	ranges := ParseAccept(header)
+	var (
+		best    string
+		quality float32
+	)
+	for _, format := range formats {
+		encoder, found := resultEncoders[format]
+		if !found {
+			resultsLog.Warn("NegotiateFormat: No encoder registered", "format", format)
+			continue
+		}
+		for _, mimeType := range encoder.MimeTypes {
+			if q := ranges.Quality(mimeType); q > quality {
+				best, quality = format, q
+			}
+		}
+	}
+	return best
+}
+
+// RenderNegotiated renders the object in the format negotiated with the client
+// out of the offered formats (all the registered formats when none are
+// offered). The html format renders the action template with the object in
+// the "result" view arg, the other formats use the registered ResultEncoder.
+// A 406 Not Acceptable error is returned when no format matches. For example:
+//
+//     func (c Hotels) Show(id int) revel.Result {
+//     	 return c.RenderNegotiated(loadHotel(id), "html", "json", "xml")
+//     }
+func (c *Controller) RenderNegotiated(obj interface{}, formats ...string) Result {
+	c.Response.Out.internalHeader.Add("Vary", "Accept")
+
+	format := NegotiateFormat(c.Request, formats...)
+	encoder, found := resultEncoders[format]
+	if !found {
+		c.Response.Status = http.StatusNotAcceptable
+		return c.RenderError(&Error{
+			Title:       "Not Acceptable",
+			Description: "None of the formats available for this resource are acceptable",
+		})
+	}
+	c.Request.Format = format
+
+	if encoder.Encode == nil {
+		if c.MethodType == nil {
+			// The template is named after the action
+			c.Response.Status = http.StatusInternalServerError
+			return c.RenderError(&Error{
+				Title:       "Template Not Found",
+				Description: "The " + format + " format is rendered with the template of the action, but no action is set",
+			})
+		}
+		c.ViewArgs["result"] = obj
+		return c.RenderTemplate(c.Name + "/" + c.MethodType.Name + "." + format)
+	}
+	c.setStatusIfNil(http.StatusOK)
+	return &RenderNegotiatedResult{obj, encoder}
+}
+
+// RenderNegotiatedResult renders the object using the encoder negotiated by
+// Controller.RenderNegotiated.
+type RenderNegotiatedResult struct {
+	obj     interface{}
+	encoder *ResultEncoder
+}
+
+func (r *RenderNegotiatedResult) Apply(req *Request, resp *Response) {
+	// Encode into a buffer first so an error can still be rendered
+	var b bytes.Buffer
+	if err := r.encoder.Encode(&b, r.obj); err != nil {
+		ErrorResult{Error: err}.Apply(req, resp)
+		return
+	}
+
+	resp.WriteHeader(http.StatusOK, r.encoder.ContentType)
+	if _, err := resp.GetWriter().Write(b.Bytes()); err != nil {
+		resultsLog.Error("Apply: Response write failed", "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccept(t *testing.T) {
+	a := assert.New(t)
+	ranges := ParseAccept("text/html;q=0.5, application/json, */*;q=0.1, text/*;level=1;q=0.8")
+	a.Equal(AcceptRanges{
+		{"application", "json", 1},
+		{"text", "*", 0.8},
+		{"text", "html", 0.5},
+		{"*", "*", 0.1},
+	}, ranges)
+
+	a.Equal(float32(0.5), ranges.Quality("text/html"), "The most specific range decides")
+	a.Equal(float32(0.8), ranges.Quality("text/plain"))
+	a.Equal(float32(0.1), ranges.Quality("application/xml"))
+	a.Equal(float32(0), ParseAccept("application/json").Quality("text/html"))
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	startFakeBookingApp()
+	for _, test := range []struct {
+		path, accept string
+		formats      []string
+		expected     string
+	}{
+		{"/hotels/3", "", nil, "html"},
+		{"/hotels/3", "text/html;q=0.1, application/json", nil, "json"},
+		{"/hotels/3", "application/xml;q=0.9, application/json;q=0.9", []string{"json", "xml"}, "json"},
+		{"/hotels/3", "application/xml;q=0.9, application/json;q=0.9", []string{"xml", "json"}, "xml"},
+		{"/hotels/3", "*/*", []string{"json", "xml"}, "json"},
+		{"/hotels/3", "text/plain", []string{"json", "xml"}, ""},
+		{"/hotels/3", "application/json, */*;q=0", []string{"xml"}, ""},
+		{"/hotels/3.xml", "application/json", []string{"json", "xml"}, "xml"},
+	} {
+		req, _ := http.NewRequest("GET", test.path, nil)
+		req.Header.Set("Accept", test.accept)
+		c := NewTestController(httptest.NewRecorder(), req)
+		if format := NegotiateFormat(c.Request, test.formats...); format != test.expected {
+			t.Errorf("%s %q %v: expected %q, was %q", test.path, test.accept, test.formats, test.expected, format)
+		}
+	}
+}
+
+func TestRenderNegotiated(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+	hotel := &Hotel{3, "A Hotel", "300 Main St.", "New York", "NY", "10010", "USA", 300}
+
+	req, _ := http.NewRequest("GET", "/hotels/3", nil)
+	req.Header.Set("Accept", "application/json")
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	c.RenderNegotiated(hotel).Apply(c.Request, c.Response)
+	a.Equal(http.StatusOK, resp.Code)
+	a.Equal("application/json; charset=utf-8", resp.Header().Get("Content-Type"))
+	a.Equal("Accept", resp.Header().Get("Vary"))
+	a.Contains(resp.Body.String(), `"Name":"A Hotel"`)
+
+	req.Header.Set("Accept", "text/plain")
+	resp = httptest.NewRecorder()
+	c = NewTestController(resp, req)
+	c.RenderNegotiated(hotel, "json", "xml").Apply(c.Request, c.Response)
+	a.Equal(http.StatusNotAcceptable, resp.Code)
+
+	req.Header.Set("Accept", "text/html")
+	c = NewTestController(httptest.NewRecorder(), req)
+	a.IsType(ErrorResult{}, c.RenderNegotiated(hotel), "Expected an error without an action")
+	a.Equal(http.StatusInternalServerError, c.Response.Status)
+
+	c = NewTestController(httptest.NewRecorder(), req)
+	a.Nil(c.SetAction("Hotels", "Show"))
+	a.IsType(&RenderTemplateResult{}, c.RenderNegotiated(hotel))
+	a.Equal(hotel, c.ViewArgs["result"])
+}
@@ -5,13 +5,20 @@
 package revel
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 // Params provides a unified view of the request params.
@@ -35,14 +42,84 @@ type Params struct {
 	Files    map[string][]*multipart.FileHeader // Files uploaded in a multipart form
 	tmpFiles []*os.File                         // Temp files used during the request.
 	JSON     []byte                             // JSON data from request body
+	Body     []byte                             // The request body, when decoded by a registered BodyDecoder
+
+	bodyDecoder *BodyDecoder // The decoder for the Body
+}
+
+// BodyDecoder decodes request bodies of a content type, see RegisterBodyDecoder.
+type BodyDecoder struct {
+	// Unmarshal decodes the body into the destination, it is used by
+	// Params.BindBody and to bind struct and map action arguments.
+	Unmarshal func(body []byte, dest interface{}) error
+}
+
+var (
+	paramsLogger = RevelLog.New("section", "params")
+
+	// The registered body decoders by content type.
+	bodyDecoders = map[string]*BodyDecoder{}
+
+	// The largest request body read, set via server.request.max.body (in MB). Only
+	// the bodies the ParamsFilter reads are limited: forms, JSON and the bodies
+	// of the registered decoders.
+	maxBodySize int64 = 32 << 20
+
+	// Returned by ParseParams when the body is larger than server.request.max.body
+	ErrBodyTooLarge = errors.New("Request body too large")
+)
+
+func init() {
+	xmlDecoder := &BodyDecoder{Unmarshal: unmarshalXML}
+	RegisterBodyDecoder("application/xml", xmlDecoder)
+	RegisterBodyDecoder("text/xml", xmlDecoder)
+	ndjsonDecoder := &BodyDecoder{Unmarshal: unmarshalNDJSON}
+	RegisterBodyDecoder("application/x-ndjson", ndjsonDecoder)
+	RegisterBodyDecoder("application/ndjson", ndjsonDecoder)
+
+	OnAppStart(func() {
+		maxBodySize = int64(Config.IntDefault("server.request.max.body", 32)) << 20
+	})
 }
 
-var paramsLogger = RevelLog.New("section", "params")
+// RegisterBodyDecoder registers the decoder for request bodies of the content
+// type. The body is read into Params.Body and is bound to struct and map action
+// arguments the same way as JSON. The body is also decoded into an
+// interface{}, which fills Params.JSON with its JSON form and Params.Values
+// with its top level fields. For example:
+//
+//	revel.RegisterBodyDecoder("application/x-yaml", &revel.BodyDecoder{Unmarshal: yaml.Unmarshal})
+func RegisterBodyDecoder(contentType string, decoder *BodyDecoder) {
+	bodyDecoders[contentType] = decoder
+}
 
 // ParseParams parses the `http.Request` params into `revel.Controller.Params`.
 func ParseParams(params *Params, req *Request) {
+	if err := parseParams(params, req, false); err != nil {
+		paramsLogger.Warn("ParseParams: Error parsing request body", "error", err)
+	}
+}
+
+// Parses the params, ErrBodyTooLarge is returned if the body exceeds the
+// maximum size. A multipart body is left unread if it is to be streamed.
+func parseParams(params *Params, req *Request, streamMultipart bool) (err error) {
+	defer func() {
+		params.Values = params.calcValues()
+	}()
 	params.Query = req.GetQuery()
 
+	// Only the bodies read here are limited, others may be streamed by the action
+	_, decodable := bodyDecoders[req.ContentType]
+	switch req.ContentType {
+	case "application/x-www-form-urlencoded", "application/json", "text/json":
+		decodable = true
+	}
+	if decodable {
+		if length, perr := strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64); perr == nil && length > maxBodySize {
+			return ErrBodyTooLarge
+		}
+	}
+
 	// Parse the body depending on the content type.
 	switch req.ContentType {
 	case "application/x-www-form-urlencoded":
@@ -53,7 +130,10 @@ func ParseParams(params *Params, req *Request) {
 		}
 
 	case "multipart/form-data":
-		// Multipart form.
+		// Multipart form, unless the action reads the MultipartStream
+		if streamMultipart {
+			break
+		}
 		if mp, err := req.GetMultipartForm(); err != nil {
 			paramsLogger.Warn("ParseParams: parsing request body:", "error", err)
 		} else {
@@ -64,18 +144,171 @@ func ParseParams(params *Params, req *Request) {
 		fallthrough
 	case "text/json":
 		if body := req.GetBody(); body != nil {
-			if content, err := ioutil.ReadAll(body); err == nil {
+			var content []byte
+			if content, err = readBody(body); err == nil {
 				// We wont bind it until we determine what we are binding too
 				params.JSON = content
-			} else {
+			} else if err != ErrBodyTooLarge {
 				paramsLogger.Error("ParseParams: Failed to ready request body bytes", "error", err)
 			}
 		} else {
 			paramsLogger.Info("ParseParams: Json post received with empty body")
 		}
+	default:
+		decoder, found := bodyDecoders[req.ContentType]
+		if !found {
+			break
+		}
+		if body := req.GetBody(); body != nil {
+			if params.Body, err = readBody(body); err == nil {
+				params.bodyDecoder = decoder
+				params.decodeBody()
+			} else if err != ErrBodyTooLarge {
+				paramsLogger.Error("ParseParams: Failed to ready request body bytes", "error", err)
+			}
+		}
 	}
+	return
+}
 
-	params.Values = params.calcValues()
+// Decodes the body of a registered decoder generically, filling the JSON with
+// its JSON form and the Form with its top level fields.
+func (p *Params) decodeBody() {
+	var data interface{}
+	if err := p.bodyDecoder.Unmarshal(p.Body, &data); err != nil {
+		paramsLogger.Debug("ParseParams: Body not decodable into parameters", "error", err)
+		return
+	}
+	data = normalizeDecoded(data)
+	if content, err := json.Marshal(data); err == nil {
+		p.JSON = content
+	}
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	p.Form = url.Values{}
+	for name, field := range fields {
+		items, isList := field.([]interface{})
+		if !isList {
+			items = []interface{}{field}
+		}
+		for _, item := range items {
+			switch item := item.(type) {
+			case string:
+				p.Form.Add(name, item)
+			case float64:
+				p.Form.Add(name, strconv.FormatFloat(item, 'f', -1, 64))
+			case bool, int, int64, uint64:
+				p.Form.Add(name, fmt.Sprint(item))
+			}
+		}
+	}
+}
+
+// Converts the maps with interface{} keys, as decoded from YAML, to maps with
+// string keys so the data may be encoded as JSON.
+func normalizeDecoded(data interface{}) interface{} {
+	switch data := data.(type) {
+	case map[interface{}]interface{}:
+		fields := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			fields[fmt.Sprint(key)] = normalizeDecoded(value)
+		}
+		return fields
+	case map[string]interface{}:
+		for key, value := range data {
+			data[key] = normalizeDecoded(value)
+		}
+	case []interface{}:
+		for i, value := range data {
+			data[i] = normalizeDecoded(value)
+		}
+	}
+	return data
+}
+
+// Decodes an XML body, into a map of the children of the root element when
+// the destination is an *interface{}.
+func unmarshalXML(body []byte, dest interface{}) error {
+	generic, ok := dest.(*interface{})
+	if !ok {
+		return xml.Unmarshal(body, dest)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			*generic, err = decodeXMLElement(decoder)
+			return err
+		}
+	}
+}
+
+// Decodes the content of an element, a map of its children or its text. The
+// children of the same name are collected in a slice.
+func decodeXMLElement(decoder *xml.Decoder) (interface{}, error) {
+	var text bytes.Buffer
+	var children map[string]interface{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if children == nil {
+				children = map[string]interface{}{}
+			}
+			switch existing := children[token.Name.Local].(type) {
+			case nil:
+				children[token.Name.Local] = value
+			case []interface{}:
+				children[token.Name.Local] = append(existing, value)
+			default:
+				children[token.Name.Local] = []interface{}{existing, value}
+			}
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return strings.TrimSpace(text.String()), nil
+		}
+	}
+}
+
+// Reads the body, up to the maximum body size.
+func readBody(body io.Reader) (content []byte, err error) {
+	if content, err = ioutil.ReadAll(io.LimitReader(body, maxBodySize+1)); err == nil && int64(len(content)) > maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return
+}
+
+// Decodes newline delimited JSON into the slice pointed to by the destination.
+func unmarshalNDJSON(body []byte, dest interface{}) error {
+	var array bytes.Buffer
+	array.WriteByte('[')
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if array.Len() > 1 {
+			array.WriteByte(',')
+		}
+		array.Write(line)
+	}
+	array.WriteByte(']')
+	return json.Unmarshal(array.Bytes(), dest)
 }
 
 // Bind looks for the named parameter, converts it to the requested type, and
@@ -95,10 +328,10 @@ func (p *Params) Bind(dest interface{}, name string) {
 	// to use the json data to populate the destination interface. We do not want
 	// to do this on a named bind directly against the param, it is ok to happen when
 	// the action is invoked.
-	jsonData := p.JSON
-	p.JSON = nil
+	jsonData, bodyDecoder := p.JSON, p.bodyDecoder
+	p.JSON, p.bodyDecoder = nil, nil
 	value.Set(Bind(p, name, value.Type()))
-	p.JSON = jsonData
+	p.JSON, p.bodyDecoder = jsonData, bodyDecoder
 }
 
 // Bind binds the JSON data to the dest.
@@ -115,6 +348,37 @@ func (p *Params) BindJSON(dest interface{}) error {
 	return nil
 }
 
+// BindBody binds the request body to the dest using the decoder registered
+// for the content type of the request, JSON bodies are supported as well.
+func (p *Params) BindBody(dest interface{}) error {
+	if reflect.ValueOf(dest).Kind() != reflect.Ptr {
+		paramsLogger.Warn("BindBody: Not a pointer")
+		return errors.New("BindBody not a pointer")
+	}
+	body, unmarshal := p.bodyUnmarshaler()
+	if unmarshal == nil {
+		return errors.New("BindBody no decodable body found")
+	}
+	if err := unmarshal(body, dest); err != nil {
+		paramsLogger.Warn("BindBody: Unable to unmarshal request:", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Returns the body and the function to unmarshal it with, nil if there is no
+// body to be bound.
+func (p *Params) bodyUnmarshaler() ([]byte, func([]byte, interface{}) error) {
+	// The body of a decoder is bound as is, its JSON form may lose details
+	if p.bodyDecoder != nil {
+		return p.Body, p.bodyDecoder.Unmarshal
+	}
+	if p.JSON != nil {
+		return p.JSON, json.Unmarshal
+	}
+	return nil, nil
+}
+
 // calcValues returns a unified view of the component param maps.
 func (p *Params) calcValues() url.Values {
 	numParams := len(p.Query) + len(p.Fixed) + len(p.Route) + len(p.Form)
@@ -164,7 +428,16 @@ func (p *Params) calcValues() url.Values {
 }
 
 func ParamsFilter(c *Controller, fc []Filter) {
-	ParseParams(c.Params, c.Request)
+	if err := parseParams(c.Params, c.Request, streamsMultipart(c.MethodType)); err == ErrBodyTooLarge {
+		c.Response.Status = http.StatusRequestEntityTooLarge
+		c.Result = c.RenderError(&Error{
+			Title:       "Request Entity Too Large",
+			Description: "The request body exceeds the maximum size allowed",
+		})
+		return
+	} else if err != nil {
+		paramsLogger.Warn("ParamsFilter: Error parsing request body", "error", err)
+	}
 
 	// Clean up from the request.
 	defer func() {
@@ -135,6 +135,87 @@ func TestBind(t *testing.T) {
 	}
 }
 
+func getBodyRequest(contentType, body string) *http.Request {
+	req, _ := http.NewRequest("POST", "http://localhost/path", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestBodyDecoders(t *testing.T) {
+	c := NewTestController(nil, getBodyRequest("application/xml; charset=utf-8", `<Hotel><Name>A Hotel</Name><Price>300</Price></Hotel>`))
+	ParamsFilter(c, NilChain)
+	var hotel Hotel
+	if err := c.Params.BindBody(&hotel); err != nil || hotel.Name != "A Hotel" || hotel.Price != 300 {
+		t.Errorf("Failed to bind the xml body: %v %#v", err, hotel)
+	}
+	// Struct arguments are bound from the body
+	bound := Bind(c.Params, "hotel", reflect.TypeOf(Hotel{})).Interface().(Hotel)
+	if bound.Name != "A Hotel" {
+		t.Errorf("Failed to bind the xml body to the argument: %#v", bound)
+	}
+	// The fields of the body are parameters, and the body is available as JSON
+	if c.Params.Get("Name") != "A Hotel" || c.Params.Get("Price") != "300" {
+		t.Errorf("Expected the fields of the xml body in the values, got %v", c.Params.Values)
+	}
+	var fields map[string]string
+	if err := c.Params.BindJSON(&fields); err != nil || fields["Name"] != "A Hotel" {
+		t.Errorf("Failed to bind the JSON form of the xml body: %v %s", err, c.Params.JSON)
+	}
+
+	c = NewTestController(nil, getBodyRequest("application/x-ndjson", "{\"Name\":\"One\"}\n\n{\"Name\":\"Two\"}\n"))
+	ParamsFilter(c, NilChain)
+	var hotels []Hotel
+	if err := c.Params.BindBody(&hotels); err != nil || len(hotels) != 2 || hotels[1].Name != "Two" {
+		t.Errorf("Failed to bind the ndjson body: %v %#v", err, hotels)
+	}
+	if string(c.Params.JSON) != `[{"Name":"One"},{"Name":"Two"}]` {
+		t.Errorf("Expected the JSON form of the ndjson body, got %s", c.Params.JSON)
+	}
+
+	c = NewTestController(nil, getBodyRequest("application/octet-stream", "binary"))
+	ParamsFilter(c, NilChain)
+	if err := c.Params.BindBody(&hotel); err == nil {
+		t.Errorf("Expected an error binding a body without a decoder")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	defer func(size int64) { maxBodySize = size }(maxBodySize)
+	maxBodySize = 10
+
+	c := NewTestController(nil, getBodyRequest("application/json", `{"Name":"A Hotel"}`))
+	invoked := false
+	ParamsFilter(c, []Filter{func(*Controller, []Filter) { invoked = true }})
+	if invoked || c.Response.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a 413, was %d", c.Response.Status)
+	}
+
+	// The declared length is rejected before the body is read
+	req := getBodyRequest("application/json", `{}`)
+	req.Header.Set("Content-Length", "1000")
+	c = NewTestController(nil, req)
+	ParamsFilter(c, NilChain)
+	if c.Response.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a 413, was %d", c.Response.Status)
+	}
+
+	// The bodies left to the action are not limited
+	req = getBodyRequest("application/octet-stream", "binary")
+	req.Header.Set("Content-Length", "1000")
+	c = NewTestController(nil, req)
+	invoked = false
+	ParamsFilter(c, []Filter{func(*Controller, []Filter) { invoked = true }})
+	if !invoked || c.Response.Status != 0 {
+		t.Errorf("Expected an unread body to be passed to the action, was %d", c.Response.Status)
+	}
+
+	c = NewTestController(nil, getBodyRequest("application/json", `{}`))
+	ParamsFilter(c, NilChain)
+	if c.Response.Status != 0 || string(c.Params.JSON) != "{}" {
+		t.Errorf("Expected the body to be read, was %d %s", c.Response.Status, c.Params.JSON)
+	}
+}
+
 func TestResolveAcceptLanguage(t *testing.T) {
 	request := buildHTTPRequestWithAcceptLanguage("")
 	if result := ResolveAcceptLanguage(request); result != nil {
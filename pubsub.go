@@ -0,0 +1,74 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"sync"
+)
+
+// PubSub delivers the messages published on a topic to every subscriber of
+// the topic. The WebSocketHub publishes its broadcasts through a PubSub, so an
+// implementation shared between instances (like the cache.RedisPubSub) fans
+// the broadcasts out to the connections on every instance.
+type PubSub interface {
+	// Publish sends the message to the subscribers of the topic.
+	Publish(topic string, message []byte) error
+	// Subscribe calls the handler with every message published on the topic,
+	// until the returned function is called.
+	Subscribe(topic string, handler func(message []byte)) (unsubscribe func(), err error)
+}
+
+// The PubSub used by a WebSocketHub which does not set one, replaced by the
+// cache.RedisPubSub when websocket.pubsub=redis.
+var DefaultPubSub PubSub = NewLocalPubSub()
+
+// LocalPubSub is a PubSub for a single instance, the messages are delivered
+// to the subscribers in process.
+type LocalPubSub struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[int]func(message []byte)
+	nextID      int
+}
+
+// NewLocalPubSub returns an empty LocalPubSub.
+func NewLocalPubSub() *LocalPubSub {
+	return &LocalPubSub{subscribers: map[string]map[int]func(message []byte){}}
+}
+
+// Publish calls the handlers subscribed to the topic in turn.
+func (ps *LocalPubSub) Publish(topic string, message []byte) error {
+	ps.mutex.RLock()
+	handlers := make([]func(message []byte), 0, len(ps.subscribers[topic]))
+	for _, handler := range ps.subscribers[topic] {
+		handlers = append(handlers, handler)
+	}
+	ps.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+// Subscribe adds the handler to the topic.
+func (ps *LocalPubSub) Subscribe(topic string, handler func(message []byte)) (unsubscribe func(), err error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	ps.nextID++
+	id := ps.nextID
+	if ps.subscribers[topic] == nil {
+		ps.subscribers[topic] = map[int]func(message []byte){}
+	}
+	ps.subscribers[topic][id] = handler
+
+	return func() {
+		ps.mutex.Lock()
+		defer ps.mutex.Unlock()
+		delete(ps.subscribers[topic], id)
+		if len(ps.subscribers[topic]) == 0 {
+			delete(ps.subscribers, topic)
+		}
+	}, nil
+}
@@ -377,6 +377,16 @@ func (r RenderTextResult) Apply(req *Request, resp *Response) {
 	}
 }
 
+// The result writing only the status, for the responses without a body.
+type statusResult struct {
+	status int
+}
+
+func (r statusResult) Apply(req *Request, resp *Response) {
+	resp.Status = r.status
+	resp.SetStatus(r.status)
+}
+
 type ContentDisposition string
 
 var (
@@ -391,21 +401,29 @@ type BinaryResult struct {
 	Length   int64
 	Delivery ContentDisposition
 	ModTime  time.Time
+	ETag     string // The entity tag, generated from the ModTime and Length when empty
 }
 
+// Apply writes the content, conditional requests (If-Match, If-None-Match,
+// If-Modified-Since, If-Unmodified-Since) and single byte range requests
+// (Range, If-Range) are answered here so they work for any engine, writer or
+// reader. Ranges require the Length to be known, a reader which is not an
+// io.ReadSeeker is skipped forward to the start of the range.
 func (r *BinaryResult) Apply(req *Request, resp *Response) {
+	// Close the Reader if we can
+	if v, ok := r.Reader.(io.Closer); ok {
+		defer func() {
+			_ = v.Close()
+		}()
+	}
+
+	header := resp.Out.internalHeader
 	if r.Delivery != NoDisposition {
 		disposition := string(r.Delivery)
 		if r.Name != "" {
 			disposition += fmt.Sprintf(`; filename="%s"`, r.Name)
 		}
-		resp.Out.internalHeader.Set("Content-Disposition", disposition)
-	}
-	if resp.ContentType != "" {
-		resp.Out.internalHeader.Set("Content-Type", resp.ContentType)
-	} else {
-		contentType := ContentTypeByFilename(r.Name)
-		resp.Out.internalHeader.Set("Content-Type", contentType)
+		header.Set("Content-Disposition", disposition)
 	}
 	if content, ok := r.Reader.(io.ReadSeeker); ok && r.Length < 0 {
 		// get the size from the stream
@@ -419,16 +437,58 @@ func (r *BinaryResult) Apply(req *Request, resp *Response) {
 		}
 	}
 
-	// Write stream writes the status code to the header as well
-	if ws := resp.GetStreamWriter(); ws != nil {
-		if err := ws.WriteStream(r.Name, r.Length, r.ModTime, r.Reader); err != nil {
-			resultsLog.Error("Apply: Response write failed", "error", err)
+	if r.ETag == "" && !r.ModTime.IsZero() && r.Length >= 0 {
+		r.ETag = fmt.Sprintf(`"%x-%x"`, r.ModTime.UnixNano(), r.Length)
+	}
+	if r.ETag != "" {
+		header.Set("ETag", r.ETag)
+	}
+	if !r.ModTime.IsZero() {
+		header.Set("Last-Modified", r.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	// Conditional and range requests only apply to a successful response
+	length := r.Length
+	if resp.Status == 0 || resp.Status == http.StatusOK {
+		if status := checkPreconditions(req, r.ETag, r.ModTime); status != 0 {
+			resp.Status = status
+			resp.SetStatus(status)
+			return
+		}
+
+		if length >= 0 {
+			header.Set("Accept-Ranges", "bytes")
+			if rangeHeader := req.GetHttpHeader("Range"); rangeHeader != "" && checkIfRange(req, r.ETag, r.ModTime) {
+				ranges, err := parseRange(rangeHeader, r.Length)
+				if err != nil {
+					header.Set("Content-Range", fmt.Sprintf("bytes */%d", r.Length))
+					resp.Status = http.StatusRequestedRangeNotSatisfiable
+					resp.SetStatus(resp.Status)
+					return
+				}
+				// Multiple ranges are answered with the full content
+				if len(ranges) == 1 {
+					if err = skipReader(r.Reader, ranges[0].start); err != nil {
+						resultsLog.Error("Apply: Failed to skip to the start of the range", "error", err)
+						ErrorResult{Error: err}.Apply(req, resp)
+						return
+					}
+					length = ranges[0].length
+					header.Set("Content-Range", ranges[0].contentRange(r.Length))
+					resp.Status = http.StatusPartialContent
+				}
+			}
 		}
 	}
 
-	// Close the Reader if we can
-	if v, ok := r.Reader.(io.Closer); ok {
-		_ = v.Close()
+	reader := r.Reader
+	if length >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+		reader = io.LimitReader(reader, length)
+	}
+	resp.WriteHeader(http.StatusOK, ContentTypeByFilename(r.Name))
+	if _, err := io.Copy(resp.GetWriter(), reader); err != nil {
+		resultsLog.Error("Apply: Response write failed", "error", err)
 	}
 }
 
@@ -5,10 +5,14 @@
 package revel
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Added test case for redirection testing for strings.
@@ -38,6 +42,88 @@ func TestBenchmarkRender(t *testing.T) {
 	}
 }
 
+// Test the conditional and range requests answered by the BinaryResult.
+func TestBinaryResultRanges(t *testing.T) {
+	startFakeBookingApp()
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	etag := fmt.Sprintf(`"%x-%x"`, modtime.UnixNano(), 10)
+
+	for _, test := range []struct {
+		headers        map[string]string
+		status         int
+		body           string
+		contentRange   string
+		seekableReader bool
+	}{
+		{map[string]string{}, http.StatusOK, "abcdefghij", "", true},
+		{map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "cde", "bytes 2-4/10", true},
+		{map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "cde", "bytes 2-4/10", false},
+		{map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij", "bytes 7-9/10", true},
+		{map[string]string{"Range": "bytes=8-"}, http.StatusPartialContent, "ij", "bytes 8-9/10", false},
+		{map[string]string{"Range": "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10", true},
+		{map[string]string{"Range": "bytes=0-1,4-5"}, http.StatusOK, "abcdefghij", "", true},
+		{map[string]string{"Range": "bytes=2-4", "If-Range": etag}, http.StatusPartialContent, "cde", "bytes 2-4/10", true},
+		{map[string]string{"Range": "bytes=2-4", "If-Range": `"other"`}, http.StatusOK, "abcdefghij", "", true},
+		{map[string]string{"Range": "bytes=2-4", "If-Range": modtime.Format(http.TimeFormat)}, http.StatusPartialContent, "cde", "bytes 2-4/10", true},
+		{map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified, "", "", true},
+		{map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified, "", "", true},
+		{map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)}, http.StatusNotModified, "", "", false},
+		{map[string]string{"If-Modified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "abcdefghij", "", false},
+		{map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed, "", "", true},
+		{map[string]string{"If-Unmodified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed, "", "", true},
+	} {
+		req, _ := http.NewRequest("GET", "/public/file.txt", nil)
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+		resp := httptest.NewRecorder()
+		c := NewTestController(resp, req)
+		var reader io.Reader = strings.NewReader("abcdefghij")
+		if !test.seekableReader {
+			reader = io.MultiReader(reader)
+		}
+		result := c.RenderBinary(reader, "file.txt", Inline, modtime).(*BinaryResult)
+		result.Length = 10
+		result.Apply(c.Request, c.Response)
+
+		if resp.Code != test.status || resp.Body.String() != test.body || resp.Header().Get("Content-Range") != test.contentRange {
+			t.Errorf("%v: expected %d %q %q, was %d %q %q", test.headers, test.status, test.body, test.contentRange,
+				resp.Code, resp.Body.String(), resp.Header().Get("Content-Range"))
+		}
+		if resp.Header().Get("ETag") != etag {
+			t.Errorf("%v: expected the ETag %s, was %s", test.headers, etag, resp.Header().Get("ETag"))
+		}
+	}
+}
+
+// Test that ranges are served uncompressed and compressed content has a weak ETag.
+func TestBinaryResultCompressed(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("results.compressed", "true")
+	defer Config.SetOption("results.compressed", "false")
+
+	for _, rangeHeader := range []string{"", "bytes=0-2"} {
+		req, _ := http.NewRequest("GET", "/public/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", rangeHeader)
+		resp := httptest.NewRecorder()
+		c := NewTestController(resp, req)
+		CompressFilter(c, []Filter{func(c *Controller, _ []Filter) {
+			c.Result = c.RenderBinary(strings.NewReader("abcdefghij"), "file.txt", Inline, time.Now())
+		}})
+		c.Result.Apply(c.Request, c.Response)
+		_ = c.Response.GetWriter().(io.Closer).Close()
+
+		if rangeHeader == "" {
+			if resp.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(resp.Header().Get("ETag"), "W/") {
+				t.Errorf("Expected gzip with a weak ETag, was %q %q", resp.Header().Get("Content-Encoding"), resp.Header().Get("ETag"))
+			}
+		} else if resp.Code != http.StatusPartialContent || resp.Body.String() != "abc" || resp.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected an uncompressed range, was %d %q %q", resp.Code, resp.Body.String(), resp.Header().Get("Content-Encoding"))
+		}
+	}
+}
+
 func BenchmarkRenderChunked(b *testing.B) {
 	startFakeBookingApp()
 	resp := httptest.NewRecorder()
@@ -71,3 +157,59 @@ func BenchmarkRenderNotChunked(b *testing.B) {
 		hotels.Show(3).Apply(c.Request, c.Response)
 	}
 }
+
+func TestEventStreamResult(t *testing.T) {
+	startFakeBookingApp()
+	req, _ := http.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "41")
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	if id := c.LastEventID(); id != "41" {
+		t.Errorf("Expected the last event id 41, got %s", id)
+	}
+
+	events := make(chan ServerSentEvent, 3)
+	events <- ServerSentEvent{ID: "42", Event: "update", Data: "line 1\nline 2"}
+	events <- ServerSentEvent{Data: map[string]int{"count": 1}, Retry: 2 * time.Second}
+	close(events)
+	c.RenderEventStream(events).Apply(c.Request, c.Response)
+
+	if contentType := resp.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Errorf("Unexpected content type %s", contentType)
+	}
+	if !resp.Flushed {
+		t.Error("Expected the event stream to be flushed")
+	}
+	expected := "id: 42\nevent: update\ndata: line 1\ndata: line 2\n\nretry: 2000\ndata: {\"count\":1}\n\n"
+	if body := resp.Body.String(); body != expected {
+		t.Errorf("Unexpected event stream %q", body)
+	}
+}
+
+func TestEventStreamResultDisconnect(t *testing.T) {
+	startFakeBookingApp()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "/events", nil)
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req.WithContext(ctx))
+
+	// The channel is never closed, the stream ends when the client goes away
+	events := make(chan ServerSentEvent)
+	result := c.RenderEventStream(events).(*EventStreamResult)
+	result.Heartbeat = 10 * time.Millisecond
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	done := make(chan struct{})
+	go func() {
+		result.Apply(c.Request, c.Response)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The event stream did not end when the client disconnected")
+	}
+	if !strings.Contains(resp.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("Expected heartbeats on the idle stream, got %q", resp.Body.String())
+	}
+}
@@ -67,7 +67,7 @@ var (
 	HTTPAddr    string // e.g. "", "127.0.0.1"
 	HTTPSsl     bool   // e.g. true if using ssl
 	HTTPSslCert string // e.g. "/path/to/cert.pem"
-	HTTPSslKey  string // e.g. "/path/to/key.pem"
+	HTTPSslKey  string // e.g. "/path/to/key.pem", additional pairs are served by SNI via http.sslcert.<name>, http.sslkey.<name>
 
 	// All cookies dropped by the framework begin with this prefix.
 	CookiePrefix string
@@ -76,6 +76,8 @@ var (
 	// Cookie flags.
 	CookieSecure   bool
 	CookieSameSite http.SameSite
+	// True to encrypt the session and flash cookies, rather than only signing them.
+	CookieEncrypt bool
 
 	// Revel request access log, not exposed from package.
 	// However output settings can be controlled from app.conf.
@@ -85,6 +87,7 @@ var (
 
 	// Private.
 	secretKey      []byte                // Key used to sign cookies. An empty key disables signing.
+	previousKeys   [][]byte              // Keys replaced by the secretKey, still accepted for verifying signatures and decrypting cookies
 	packaged       bool                  // If true, this is running from a pre-built package.
 	initEventList  = []EventHandler{}    // Event handler list for receiving events
 	packagePathMap = map[string]string{} // The map of the directories needed
@@ -191,6 +194,20 @@ func Init(inputmode, importPath, srcPath string) {
 	if secretStr := Config.StringDefault("app.secret", ""); secretStr != "" {
 		SetSecretKey([]byte(secretStr))
 	}
+	var keys [][]byte
+	for _, previous := range splitConfigList(Config.StringDefault("app.secret.previous", "")) {
+		keys = append(keys, []byte(previous))
+	}
+	SetPreviousSecretKeys(keys...)
+	algorithm := Config.StringDefault("app.secret.algorithm", "sha1")
+	previousAlgorithms := splitConfigList(Config.StringDefault("app.secret.algorithm.previous", ""))
+	if err := SetSignatureAlgorithm(algorithm, previousAlgorithms...); err != nil {
+		RevelLog.Fatal("Invalid app.secret.algorithm, expected sha1 or sha256.", "algorithm", algorithm, "previous", previousAlgorithms, "error", err)
+	}
+	CookieEncrypt = Config.BoolDefault("cookie.encrypt", false)
+	if CookieEncrypt && len(secretKey) == 0 {
+		RevelLog.Fatal("No app.secret provided, it is required by cookie.encrypt.")
+	}
 
 	RaiseEvent(REVEL_BEFORE_MODULES_LOADED, nil)
 	loadModules()
@@ -268,6 +285,12 @@ func SetSecretKey(newKey []byte) error {
 	return nil
 }
 
+// Set the secret keys used before the current one, so the signatures and
+// cookies made with them are still accepted while the key is rotated.
+func SetPreviousSecretKeys(keys ...[]byte) {
+	previousKeys = keys
+}
+
 // ResolveImportPath returns the filesystem path for the given import path.
 // Returns an error if the import path could not be found.
 func ResolveImportPath(importPath string) (string, error) {
@@ -0,0 +1,129 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// The controller argument holding the handler of the route.
+const routeHandlerArg = "route.handler"
+
+// RouteHandler is the controller of the routes added with AddRouteHandler,
+// its action invokes the handler of the route.
+type RouteHandler struct {
+	*Controller
+}
+
+// Handle invokes the handler of the route.
+func (c RouteHandler) Handle() Result {
+	handler, _ := c.Args[routeHandlerArg].(func(c *Controller) Result)
+	if handler == nil {
+		return c.NotFound("No handler for the route")
+	}
+	return handler(c.Controller)
+}
+
+// The type of the RouteHandler controller, which is not registered with the
+// application controllers.
+var routeHandlerType = func() *ControllerType {
+	controllerType := &ControllerType{
+		Type:    reflect.TypeOf(RouteHandler{}),
+		Methods: []*MethodType{{Name: "Handle", lowerName: "handle"}},
+	}
+	controllerType.ControllerIndexes = findControllers(controllerType.Type)
+	controllerType.ControllerEvents = NewControllerTypeEvents(controllerType)
+	return controllerType
+}()
+
+// AddRoute adds a route to the MainRouter, as a line of the routes file would.
+// The routes added in Go are kept when the routes file is refreshed, and are
+// matched before its routes. It may be called while the server is running,
+// though the routes are usually added when the application starts, e.g.
+//
+//	revel.OnAppStart(func() {
+//		if _, err := revel.AddRoute("GET", "/admin/jobs", "Jobs.Index", revel.RouteName("jobs")); err != nil {
+//			revel.AppLog.Error("Failed to add the jobs route", "error", err)
+//		}
+//	})
+//
+// The action may have fixed arguments, e.g. `Static.Serve("public")`. An
+// error is returned if the route does not fit in the routing tree, the route
+// is not added then.
+func AddRoute(method, path, action string, options ...RouteOption) (*Route, error) {
+	return mainRouter().Group("").Add(method, path, action, options...)
+}
+
+// AddRouteHandler adds a route to the MainRouter which is handled by the
+// function rather than an action. The request runs through the filters like
+// any other, the parameters of the path are in c.Params.Route, e.g.
+//
+//	revel.AddRouteHandler("GET", "/health/{check:alpha}", func(c *revel.Controller) revel.Result {
+//		return c.RenderJSON(health.Check(c.Params.Route.Get("check")))
+//	}, revel.RouteName("health"))
+//
+// The route may be reversed by its name only. An error is returned if the
+// route could not be added, see AddRoute.
+func AddRouteHandler(method, path string, handler func(c *Controller) Result, options ...RouteOption) (*Route, error) {
+	return mainRouter().Group("").AddHandler(method, path, handler, options...)
+}
+
+// Returns the MainRouter, which is created if needed so the routes may be added
+// before it is loaded.
+func mainRouter() *Router {
+	routesPath := filepath.Join(BasePath, "conf", "routes")
+	if MainRouter == nil || MainRouter.path != routesPath {
+		MainRouter = NewRouter(routesPath)
+	}
+	return MainRouter
+}
+
+// Returns a route for the method and path invoking the handler.
+func newHandlerRoute(method, path string, handler func(c *Controller) Result) *Route {
+	route := &Route{
+		ModuleSource:     appModule,
+		Method:           strings.ToUpper(method),
+		Action:           "RouteHandler.Handle",
+		ControllerName:   "RouteHandler",
+		MethodName:       "Handle",
+		FixedParams:      []string{},
+		TypeOfController: routeHandlerType,
+		Handler:          handler,
+	}
+	route.setPath(path)
+	return route
+}
+
+// Adds the route after those added before it, ahead of the routes of the
+// routes file, and updates the tree. The routes are copied and swapped in, so
+// the route may be added while the requests are routed. If the tree cannot be
+// updated the route is removed again, so it does not break the next Refresh.
+func (router *Router) addRoute(route *Route) error {
+	router.update.Lock()
+	defer router.update.Unlock()
+	added, routes := router.added, router.routes()
+	i := len(added)
+	if i > len(routes) {
+		i = len(routes)
+	}
+	router.added = append(added[:len(added):len(added)], route)
+	router.setRoutes(append(routes[:i:i], append([]*Route{route}, routes[i:]...)...))
+	if err := router.updateTree(); err != nil {
+		routerLog.Error("addRoute: Failed to add route", "method", route.Method, "path", route.Path, "error", err)
+		router.added = added
+		router.setRoutes(routes)
+		return err
+	}
+	return nil
+}
+
+// Sets the routes, which may be read while they are swapped.
+func (router *Router) setRoutes(routes []*Route) {
+	router.lock.Lock()
+	router.Routes = routes
+	router.lock.Unlock()
+}
@@ -10,10 +10,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 
@@ -26,19 +28,27 @@ const (
 )
 
 type Route struct {
-	ModuleSource        *Module         // Module name of route
-	Method              string          // e.g. GET
-	Path                string          // e.g. /app/:id
-	Action              string          // e.g. "Application.ShowApp", "404"
-	ControllerNamespace string          // e.g. "testmodule.",
-	ControllerName      string          // e.g. "Application", ""
-	MethodName          string          // e.g. "ShowApp", ""
-	FixedParams         []string        // e.g. "arg1","arg2","arg3" (CSV formatting)
-	TreePath            string          // e.g. "/GET/app/:id"
-	TypeOfController    *ControllerType // The controller type (if route is not wild carded)
-
-	routesPath string // e.g. /Users/robfig/gocode/src/myapp/conf/routes
-	line       int    // e.g. 3
+	ModuleSource        *Module                    // Module name of route
+	Method              string                     // e.g. GET
+	Path                string                     // e.g. /app/:id, the constraints of /app/{id:int} removed
+	Host                string                     // e.g. {tenant}.example.com, empty for any host
+	Action              string                     // e.g. "Application.ShowApp", "404"
+	ControllerNamespace string                     // e.g. "testmodule.",
+	ControllerName      string                     // e.g. "Application", ""
+	MethodName          string                     // e.g. "ShowApp", ""
+	FixedParams         []string                   // e.g. "arg1","arg2","arg3" (CSV formatting)
+	TreePath            string                     // e.g. "/GET/app/:id"
+	TypeOfController    *ControllerType            // The controller type (if route is not wild carded)
+	Name                string                     // e.g. "admin.user", used for reverse routing
+	Filters             []Filter                   // The filters of the route groups, run before the action
+	Handler             func(c *Controller) Result // The handler of a route added with AddRouteHandler
+
+	routesPath  string                    // e.g. /Users/robfig/gocode/src/myapp/conf/routes
+	line        int                       // e.g. 3
+	wildcards   []string                  // The names of the path wildcards, in order
+	constraints map[string]*regexp.Regexp // The constraints of the path wildcards by name
+	host        *regexp.Regexp            // The compiled host pattern, nil for any host
+	hostParams  []string                  // The names of the host parameters, in order
 }
 
 type RouteMatch struct {
@@ -46,9 +56,11 @@ type RouteMatch struct {
 	ControllerName   string // e.g. Application
 	MethodName       string // e.g. ShowApp
 	FixedParams      []string
-	Params           map[string][]string // e.g. {id: 123}
-	TypeOfController *ControllerType     // The controller type
-	ModuleSource     *Module             // The module
+	Params           map[string][]string        // e.g. {id: 123}
+	TypeOfController *ControllerType            // The controller type
+	ModuleSource     *Module                    // The module
+	Filters          []Filter                   // The filters of the route groups
+	Handler          func(c *Controller) Result // The handler of a route added with AddRouteHandler
 }
 
 type ActionPathData struct {
@@ -101,13 +113,12 @@ func NewRoute(moduleSource *Module, method, path, action, fixedArgs, routesPath
 	r = &Route{
 		ModuleSource: moduleSource,
 		Method:       strings.ToUpper(method),
-		Path:         path,
 		Action:       string(namespaceReplace([]byte(action), moduleSource)),
 		FixedParams:  fargs,
-		TreePath:     treePath(strings.ToUpper(method), path),
 		routesPath:   routesPath,
 		line:         line,
 	}
+	r.setPath(path)
 
 	// URL pattern
 	if !strings.HasPrefix(r.Path, "/") {
@@ -115,10 +126,17 @@ func NewRoute(moduleSource *Module, method, path, action, fixedArgs, routesPath
 		return
 	}
 
+	r.resolveAction()
+	return
+}
+
+// Resolves the controller and method of the route action, and stores the
+// route in the action path cache for the reverse routing.
+func (r *Route) resolveAction() {
 	// Ignore the not found status code
-	if action != httpStatusCode {
-		routerLog.Debugf("NewRoute: New splitActionPath path:%s action:%s", path, action)
-		pathData, found := splitActionPath(&ActionPathData{ModuleSource: moduleSource, Route: r}, r.Action, false)
+	if r.Action != httpStatusCode {
+		routerLog.Debugf("NewRoute: New splitActionPath path:%s action:%s", r.Path, r.Action)
+		pathData, found := splitActionPath(&ActionPathData{ModuleSource: r.ModuleSource, Route: r}, r.Action, false)
 		if found {
 			if pathData.TypeOfController != nil {
 				// Assign controller type to avoid looking it up based on name
@@ -143,10 +161,23 @@ func NewRoute(moduleSource *Module, method, path, action, fixedArgs, routesPath
 
 			// The same action path could be used for multiple routes (like the Static.Serve)
 		} else {
-			routerLog.Panicf("NewRoute: Failed to find controller for route path action %s \n%#v\n", path+"?"+r.Action, actionPathCacheMap)
+			routerLog.Panicf("NewRoute: Failed to find controller for route path action %s \n%#v\n", r.Path+"?"+r.Action, actionPathCacheMap)
 		}
 	}
-	return
+}
+
+// Sets the host and path of the route, parsing their parameters.
+func (r *Route) setPath(path string) {
+	host, path := splitRouteHost(path)
+	path, constraints, err := parseRouteConstraints(path)
+	if err != nil {
+		routerLog.Error("NewRoute: Invalid route constraint", "error", err, "path", path)
+	}
+	if r.host, r.hostParams, err = parseRouteHost(host); err != nil {
+		routerLog.Error("NewRoute: Invalid route host", "error", err, "host", host)
+	}
+	r.Host, r.Path, r.TreePath = host, path, treePath(r.Method, path)
+	r.wildcards, r.constraints = pathWildcards(path), constraints
 }
 
 func (route *Route) ActionPath() string {
@@ -160,34 +191,238 @@ func treePath(method, path string) string {
 	return "/" + method + path
 }
 
+// RouteConstraints holds the named constraints of the route parameters, as
+// regular expressions the whole parameter must match. An application may add
+// its own before the routes are loaded.
+var RouteConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"slug":  `[a-z0-9]+(-[a-z0-9]+)*`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// Replaces the constrained parameters of the path by wildcards, returning the
+// compiled constraints by parameter name. A parameter is written either
+// {name:constraint}, where the constraint is the name of one of the
+// RouteConstraints or a regular expression (without slashes or spaces), or
+// {name}, which is constrained only if the name is one of the
+// RouteConstraints, e.g.
+//
+//	GET /users/{id:int}          Users.Show
+//	GET /users/{name:[a-z-]+}    Users.ShowByName
+//	GET /sessions/{uuid}         Sessions.Show
+//
+// The path is returned unchanged if a constraint is invalid.
+func parseRouteConstraints(path string) (string, map[string]*regexp.Regexp, error) {
+	if !strings.Contains(path, "{") {
+		return path, nil, nil
+	}
+	constraints := map[string]*regexp.Regexp{}
+	elements := strings.Split(path, "/")
+	for i, el := range elements {
+		if !strings.HasPrefix(el, "{") {
+			continue
+		}
+		name, pattern, rest, err := parseRouteParameter(el)
+		if err != nil {
+			return path, nil, err
+		}
+		if pattern != "" {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return path, nil, fmt.Errorf("invalid constraint of parameter %s: %s", name, err)
+			}
+			constraints[name] = re
+		}
+		// Anything after the braces is kept, e.g. an extension
+		elements[i] = ":" + name + rest
+	}
+	return strings.Join(elements, "/"), constraints, nil
+}
+
+// Parses the parameter in braces starting the element, returning its name,
+// the regular expression it is constrained by if any, and the rest of the
+// element.
+func parseRouteParameter(el string) (name, pattern, rest string, err error) {
+	// Find the closing brace, the expression may hold braces too
+	end, depth := -1, 0
+	for j, r := range el {
+		if r == '{' {
+			depth++
+		} else if r == '}' {
+			if depth--; depth == 0 {
+				end = j
+				break
+			}
+		}
+	}
+	if end == -1 {
+		return "", "", "", fmt.Errorf("unclosed parameter %s", el)
+	}
+
+	name, rest = el[1:end], el[end+1:]
+	if colon := strings.Index(name, ":"); colon != -1 {
+		name, pattern = name[:colon], name[colon+1:]
+	} else if _, found := RouteConstraints[name]; found {
+		pattern = name
+	}
+	if name == "" {
+		return "", "", "", fmt.Errorf("unnamed parameter %s", el)
+	}
+	if constraint, found := RouteConstraints[pattern]; found {
+		pattern = constraint
+	}
+	return
+}
+
+// Returns the names of the wildcards of the path, as pathtree records them.
+func pathWildcards(path string) (wildcards []string) {
+	for _, el := range strings.Split(path, "/") {
+		if el != "" && (el[0] == ':' || el[0] == '*') {
+			wildcards = append(wildcards, el[1:])
+		}
+	}
+	if n := len(wildcards); n > 0 {
+		if dot := strings.LastIndex(wildcards[n-1], "."); dot != -1 {
+			wildcards[n-1] = wildcards[n-1][:dot]
+		}
+	}
+	return
+}
+
+// Returns the tree path with the wildcard names removed, the routes differing
+// only by the names of their wildcards are added to the same leaf.
+func routeShape(treePath string) string {
+	elements := strings.Split(treePath, "/")
+	for i, el := range elements {
+		if el != "" && (el[0] == ':' || el[0] == '*') {
+			if dot := strings.LastIndex(el, "."); dot != -1 {
+				elements[i] = el[:1] + el[dot:]
+			} else {
+				elements[i] = el[:1]
+			}
+		}
+	}
+	return strings.Join(elements, "/")
+}
+
+// Returns the parameters of the route for the wildcard expansions and the
+// host, false if they do not satisfy the constraints of the route.
+func (route *Route) matchParams(leaf *pathtree.Leaf, expansions []string, host string) (params url.Values, ok bool) {
+	hostValues, ok := route.matchHost(host)
+	if !ok {
+		return nil, false
+	}
+	if len(expansions) == 0 && len(hostValues) == 0 {
+		return nil, true
+	}
+	names := route.wildcards
+	if len(names) != len(expansions) {
+		names = leaf.Wildcards
+	}
+	params = make(url.Values)
+	for i, v := range hostValues {
+		params[route.hostParams[i]] = []string{v}
+	}
+	for i, v := range expansions {
+		params[names[i]] = []string{v}
+	}
+	for name, constraint := range route.constraints {
+		if !constraint.MatchString(params.Get(name)) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
 type Router struct {
-	Routes []*Route
-	Tree   *pathtree.Node
-	Module string // The module the route is associated with
-	path   string // path to the routes file
+	Routes  []*Route
+	Tree    *pathtree.Node
+	Module  string   // The module the route is associated with
+	path    string   // path to the routes file
+	methods []string // The methods of the routes, sorted
+	added   []*Route // The routes added in Go, kept when the routes file is refreshed
+	shapes  []routeShapeTree
+	names   map[string]*Route // The routes by name
+	lock    sync.RWMutex      // Guards the routes and the trees, which are swapped as a whole
+	update  sync.Mutex        // Serializes the updates of the routes
+}
+
+// A tree holding the routes of a single shape, see Router.route.
+type routeShapeTree struct {
+	tree   *pathtree.Node
+	routes []*Route
 }
 
+// The methods a route for any method (*) is assumed to allow.
+var anyMethodAllows = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
 func (router *Router) Route(req *Request) (routeMatch *RouteMatch) {
 	// Override method if set in header
 	if method := req.GetHttpHeader("X-HTTP-Method-Override"); method != "" && req.Method == "POST" {
 		req.Method = method
 	}
+	return router.route(req.Method, req.Host, req.GetPath())
+}
 
-	leaf, expansions := router.Tree.Find(treePath(req.Method, req.GetPath()))
-	if leaf == nil {
-		return nil
+// AllowedMethods returns the methods routed for the host and path, including
+// OPTIONS if there are any. It returns nil if no route matches the path.
+func (router *Router) AllowedMethods(host, path string) (methods []string) {
+	router.lock.RLock()
+	routerMethods := router.methods
+	router.lock.RUnlock()
+	for _, method := range routerMethods {
+		if method == "WS" || method == "OPTIONS" {
+			continue
+		}
+		if routeMatch := router.route(method, host, path); routeMatch != nil && routeMatch != notFound {
+			methods = append(methods, method)
+		}
 	}
+	if len(methods) > 0 {
+		methods = append(methods, "OPTIONS")
+	}
+	return
+}
 
-	// Create a map of the route parameters.
-	var params url.Values
-	if len(expansions) > 0 {
-		params = make(url.Values)
-		for i, v := range expansions {
-			params[leaf.Wildcards[i]] = []string{v}
+// Returns the route matching the method, host and path. The tree finds the
+// routes of a single shape, if none of them accepts the parameters (the
+// constraints or the host do not match) the routes of the other shapes are
+// tried in the order of the routes file.
+func (router *Router) route(method, host, path string) *RouteMatch {
+	router.lock.RLock()
+	tree, shapes := router.Tree, router.shapes
+	router.lock.RUnlock()
+	routeMatch, leaf := routeTree(tree, method, host, path)
+	if routeMatch != nil || leaf == nil {
+		return routeMatch
+	}
+	tried := leaf.Value.([]*Route)
+	for _, shape := range shapes {
+		if shape.routes[0] == tried[0] {
+			continue
+		}
+		if routeMatch, _ = routeTree(shape.tree, method, host, path); routeMatch != nil {
+			return routeMatch
 		}
 	}
+	return nil
+}
+
+// Returns the route of the tree matching the method, host and path, and the
+// leaf found for the path.
+func routeTree(tree *pathtree.Node, method, host, path string) (routeMatch *RouteMatch, leaf *pathtree.Leaf) {
+	leaf, expansions := tree.Find(treePath(method, path))
+	if leaf == nil {
+		return
+	}
+
+	var params url.Values
 	var route *Route
 	var controllerName, methodName string
+	matched := false
 
 	// The leaf value is now a list of possible routes to match, only a controller
 	routeList := leaf.Value.([]*Route)
@@ -196,6 +431,15 @@ func (router *Router) Route(req *Request) (routeMatch *RouteMatch) {
 	// INFO.Printf("Found route for path %s %#v", req.URL.Path, len(routeList))
 	for index := range routeList {
 		route = routeList[index]
+
+		// Create a map of the route parameters, skipping the route if they
+		// do not satisfy its constraints.
+		var ok bool
+		if params, ok = route.matchParams(leaf, expansions, host); !ok {
+			route = nil
+			continue
+		}
+		matched = true
 		methodName = route.MethodName
 
 		// Special handling for explicit 404's.
@@ -227,7 +471,10 @@ func (router *Router) Route(req *Request) (routeMatch *RouteMatch) {
 		route = nil
 	}
 
-	if route == nil {
+	if !matched {
+		// No route of the path accepts the parameters
+		return
+	} else if route == nil {
 		routeMatch = notFound
 	} else {
 		routeMatch = &RouteMatch{
@@ -237,6 +484,8 @@ func (router *Router) Route(req *Request) (routeMatch *RouteMatch) {
 			FixedParams:      route.FixedParams,
 			TypeOfController: typeOfController,
 			ModuleSource:     route.ModuleSource,
+			Filters:          route.Filters,
+			Handler:          route.Handler,
 		}
 	}
 
@@ -247,7 +496,20 @@ func (router *Router) Route(req *Request) (routeMatch *RouteMatch) {
 // Returns an error if a specified action could not be found.
 func (router *Router) Refresh() (err *Error) {
 	RaiseEvent(ROUTE_REFRESH_REQUESTED, nil)
-	router.Routes, err = parseRoutesFile(appModule, router.path, "", true)
+	router.update.Lock()
+	defer router.update.Unlock()
+	routes, err := parseRoutesFile(appModule, router.path, "", true)
+	if err == nil {
+		// The routes added in Go come first, and their actions are resolved
+		// again for the reverse routing
+		for _, route := range router.added {
+			if route.Handler == nil {
+				route.resolveAction()
+			}
+		}
+		routes = append(append([]*Route{}, router.added...), routes...)
+	}
+	router.setRoutes(routes)
 	RaiseEvent(ROUTE_REFRESH_COMPLETED, nil)
 	if err != nil {
 		return
@@ -256,39 +518,83 @@ func (router *Router) Refresh() (err *Error) {
 	return
 }
 
+// Builds the trees of the routes, and swaps them in once they are complete so
+// the requests being routed meanwhile see either the old or the new ones.
 func (router *Router) updateTree() *Error {
-	router.Tree = pathtree.New()
+	tree := pathtree.New()
 	pathMap := map[string][]*Route{}
+	methods := map[string]bool{}
+	names := map[string]*Route{}
 
 	allPathsOrdered := []string{}
 	// It is possible for some route paths to overlap
 	// based on wildcard matches,
 	// TODO when pathtree is fixed (made to be smart enough to not require a predefined intake order) keeping the routes in order is not necessary
 	for _, route := range router.Routes {
-		if _, found := pathMap[route.TreePath]; !found {
-			pathMap[route.TreePath] = append(pathMap[route.TreePath], route)
-			allPathsOrdered = append(allPathsOrdered, route.TreePath)
+		if route.Name != "" {
+			if names[route.Name] != nil {
+				return routeError(errors.New("duplicate route name "+route.Name), route.routesPath, "", route.line)
+			}
+			names[route.Name] = route
+		}
+		switch method := strings.ToUpper(route.Method); method {
+		case "*":
+			for _, method := range anyMethodAllows {
+				methods[method] = true
+			}
+		case "GET":
+			methods["GET"], methods["HEAD"] = true, true
+		default:
+			methods[method] = true
+		}
+		shape := routeShape(route.TreePath)
+		if _, found := pathMap[shape]; !found {
+			pathMap[shape] = append(pathMap[shape], route)
+			allPathsOrdered = append(allPathsOrdered, shape)
 		} else {
-			pathMap[route.TreePath] = append(pathMap[route.TreePath], route)
+			pathMap[shape] = append(pathMap[shape], route)
 		}
 	}
-	for _, path := range allPathsOrdered {
-		routeList := pathMap[path]
-		err := router.Tree.Add(path, routeList)
+	shapes := make([]routeShapeTree, 0, len(allPathsOrdered))
+	for _, shape := range allPathsOrdered {
+		routeList := pathMap[shape]
+		path := routeList[0].TreePath
+		shapeTree := routeShapeTree{tree: pathtree.New(), routes: routeList}
+		for _, tree := range []*pathtree.Node{tree, shapeTree.tree} {
+			err := tree.Add(path, routeList)
+
+			// Allow GETs to respond to HEAD requests.
+			if err == nil && routeList[0].Method == "GET" {
+				err = tree.Add(treePath("HEAD", routeList[0].Path), routeList)
+			}
 
-		// Allow GETs to respond to HEAD requests.
-		if err == nil && routeList[0].Method == "GET" {
-			err = router.Tree.Add(treePath("HEAD", routeList[0].Path), routeList)
+			// Error adding a route to the pathtree.
+			if err != nil {
+				return routeError(err, path, fmt.Sprintf("%#v", routeList), routeList[0].line)
+			}
 		}
+		shapes = append(shapes, shapeTree)
+	}
 
-		// Error adding a route to the pathtree.
-		if err != nil {
-			return routeError(err, path, fmt.Sprintf("%#v", routeList), routeList[0].line)
-		}
+	methodList := make([]string, 0, len(methods))
+	for method := range methods {
+		methodList = append(methodList, method)
 	}
+	sort.Strings(methodList)
+
+	router.lock.Lock()
+	router.Tree, router.shapes, router.methods, router.names = tree, shapes, methodList, names
+	router.lock.Unlock()
 	return nil
 }
 
+// Returns the routes, which may be swapped while they are read.
+func (router *Router) routes() []*Route {
+	router.lock.RLock()
+	defer router.lock.RUnlock()
+	return router.Routes
+}
+
 // Returns the controller namespace and name, action and module if found from the actionPath specified.
 func splitActionPath(actionPathData *ActionPathData, actionPath string, useCache bool) (pathData *ActionPathData, found bool) {
 	actionPath = strings.ToLower(actionPath)
@@ -442,6 +748,8 @@ func parseRoutesFile(moduleSource *Module, routesPath, joinedPath string, valida
 // parseRoutes reads the content of a routes file into the routing table.
 func parseRoutes(moduleSource *Module, routesPath, joinedPath, content string, validate bool) ([]*Route, *Error) {
 	var routes []*Route
+	// The groups enclosing the current line, innermost last
+	var groups []routeGroupLine
 
 	// For each line..
 	for n, line := range strings.Split(content, "\n") {
@@ -450,32 +758,65 @@ func parseRoutes(moduleSource *Module, routesPath, joinedPath, content string, v
 			continue
 		}
 
+		// Handle the route groups.
+		// e.g. "group /admin auth {" prefixes the routes up to "}" with /admin
+		// and runs the "auth" filter before their actions.
+		if line == "}" {
+			if len(groups) == 0 {
+				return nil, routeError(errors.New("unexpected } without a group"), routesPath, content, n)
+			}
+			groups = groups[:len(groups)-1]
+			continue
+		}
+		if group, found, err := parseRouteGroupLine(line); err != nil {
+			return nil, routeError(err, routesPath, content, n)
+		} else if found {
+			groups = append(groups, group)
+			continue
+		}
+		groupPath, groupFilters := joinedPath, []Filter(nil)
+		for _, group := range groups {
+			groupPath = joinRoutePath(groupPath, group.prefix)
+			groupFilters = append(groupFilters, group.filters...)
+		}
+
 		const modulePrefix = "module:"
 
 		// Handle included routes from modules.
 		// e.g. "module:testrunner" imports all routes from that module.
 		if strings.HasPrefix(line, modulePrefix) {
-			moduleRoutes, err := getModuleRoutes(line[len(modulePrefix):], joinedPath, validate)
+			moduleRoutes, err := getModuleRoutes(line[len(modulePrefix):], groupPath, validate)
 			if err != nil {
 				return nil, routeError(err, routesPath, content, n)
 			}
-			routes = append(routes, moduleRoutes...)
+			routes = append(routes, withRouteFilters(moduleRoutes, groupFilters)...)
 			continue
 		}
 
-		// A single route
+		// A single route, optionally named
+		var name string
+		line, name = parseRouteName(line)
 		method, path, action, fixedArgs, found := parseRouteLine(line)
 		if !found {
 			continue
 		}
+		if err := checkRoutePath(path); err != nil {
+			return nil, routeError(err, routesPath, content, n)
+		}
+
+		// The host of the route, or of its group, comes before the path
+		host, groupPath := splitRouteHost(groupPath)
+		if routeHost, routePath := splitRouteHost(path); routeHost != "" {
+			host, path = routeHost, routePath
+		}
 
 		// this will avoid accidental double forward slashes in a route.
 		// this also avoids pathtree freaking out and causing a runtime panic
 		// because of the double slashes
-		if strings.HasSuffix(joinedPath, "/") && strings.HasPrefix(path, "/") {
-			joinedPath = joinedPath[0 : len(joinedPath)-1]
+		if strings.HasSuffix(groupPath, "/") && strings.HasPrefix(path, "/") {
+			groupPath = groupPath[0 : len(groupPath)-1]
 		}
-		path = strings.Join([]string{AppRoot, joinedPath, path}, "")
+		path = host + strings.Join([]string{AppRoot, groupPath, path}, "")
 
 		// This will import the module routes under the path described in the
 		// routes file (joinedPath param). e.g. "* /jobs module:jobs" -> all
@@ -486,11 +827,13 @@ func parseRoutes(moduleSource *Module, routesPath, joinedPath, content string, v
 			if err != nil {
 				return nil, routeError(err, routesPath, content, n)
 			}
-			routes = append(routes, moduleRoutes...)
+			routes = append(routes, withRouteFilters(moduleRoutes, groupFilters)...)
 			continue
 		}
 
 		route := NewRoute(moduleSource, method, path, action, fixedArgs, routesPath, n)
+		route.Name = name
+		route.Filters = groupFilters
 		routes = append(routes, route)
 
 		if validate {
@@ -499,6 +842,9 @@ func parseRoutes(moduleSource *Module, routesPath, joinedPath, content string, v
 			}
 		}
 	}
+	if len(groups) > 0 {
+		return nil, routeError(errors.New("group "+groups[len(groups)-1].prefix+" is not closed"), routesPath, content, strings.Count(content, "\n"))
+	}
 
 	return routes, nil
 }
@@ -632,6 +978,10 @@ func (router *Router) ReverseError(action string, argValues map[string]string, r
 	} else {
 		log = routerLog.New("action", action)
 	}
+	// Named routes are reversed regardless of their action
+	if route := router.RouteByName(action); route != nil {
+		return route.reverse(action, argValues, nil, log)
+	}
 	pathData, found := splitActionPath(nil, action, true)
 	if !found {
 		log.Error("splitActionPath: Failed to find reverse route", "action", action, "arguments", argValues)
@@ -643,7 +993,7 @@ func (router *Router) ReverseError(action string, argValues map[string]string, r
 		var possibleRoute *Route
 		// If the route is nil then we need to go through the routes to find the first matching route
 		// from this controllers namespace, this is likely a wildcard route match
-		for _, route := range router.Routes {
+		for _, route := range router.routes() {
 			// Skip routes that are not wild card or empty
 			if route.ControllerName == "" || route.MethodName == "" {
 				continue
@@ -708,62 +1058,86 @@ func (router *Router) ReverseError(action string, argValues map[string]string, r
 			err = errors.New("Reverse: Controller not found in reverse lookup")
 			return
 		}
-		var (
-			queryValues  = make(url.Values)
-			pathElements = strings.Split(route.Path, "/")
-		)
-		for i, el := range pathElements {
-			if el == "" || (el[0] != ':' && el[0] != '*') {
-				continue
-			}
-			val, ok := pathData.FixedParamsByName[el[1:]]
-			if !ok {
-				val, ok = argValues[el[1:]]
-			}
-			if !ok {
-				val = "<nil>"
-				log.Error("Reverse: reverse route missing route argument ", "argument", el[1:])
-				err = errors.New("Missing route argument")
-				panic("Check stack")
-			}
-			pathElements[i] = val
-			delete(argValues, el[1:])
-			continue
-		}
+		ad, err = route.reverse(action, argValues, pathData.FixedParamsByName, log)
+		return
+	}
 
-		// Add any args that were not inserted into the path into the query string.
-		for k, v := range argValues {
-			queryValues.Set(k, v)
-		}
+	routerLog.Error("Reverse: Failed to find controller for reverse route", "action", action, "arguments", argValues)
+	err = errors.New("Reverse: Failed to find controller for reverse route")
+	return
+}
 
-		// Calculate the final URL and Method
-		urlPath := strings.Join(pathElements, "/")
-		if len(queryValues) > 0 {
-			urlPath += "?" + queryValues.Encode()
+// Returns the action definition of the route for the arguments, those not in
+// the path are added to the query string.
+func (route *Route) reverse(action string, argValues, fixedParamsByName map[string]string, log logger.MultiLogger) (ad *ActionDefinition, err error) {
+	var (
+		queryValues  = make(url.Values)
+		pathElements = strings.Split(route.Path, "/")
+	)
+	host := ""
+	if route.Host != "" {
+		if host, err = route.reverseHost(argValues); err != nil {
+			log.Error("Reverse: reverse route missing host argument", "error", err)
+			return
 		}
-
-		method := route.Method
-		star := false
-		if route.Method == "*" {
-			method = "GET"
-			star = true
+	}
+	for i, el := range pathElements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
 		}
+		val, ok := fixedParamsByName[el[1:]]
+		if !ok {
+			val, ok = argValues[el[1:]]
+		}
+		if !ok {
+			val = "<nil>"
+			log.Error("Reverse: reverse route missing route argument ", "argument", el[1:])
+			err = errors.New("Missing route argument")
+			panic("Check stack")
+		}
+		pathElements[i] = val
+		delete(argValues, el[1:])
+		continue
+	}
 
-		log.Debugf("Reversing action %s to %s Using Route %#v", action, urlPath, pathData.Route)
+	// Add any args that were not inserted into the path into the query string.
+	for k, v := range argValues {
+		queryValues.Set(k, v)
+	}
 
-		ad = &ActionDefinition{
-			URL:    urlPath,
-			Method: method,
-			Star:   star,
-			Action: action,
-			Args:   argValues,
-			Host:   "TODO",
+	// Calculate the final URL and Method
+	urlPath := strings.Join(pathElements, "/")
+	if len(queryValues) > 0 {
+		urlPath += "?" + queryValues.Encode()
+	}
+	if host != "" {
+		// The routes of a host are reversed to absolute URLs
+		scheme := "http"
+		if HTTPSsl {
+			scheme = "https"
 		}
-		return
+		urlPath = scheme + "://" + host + urlPath
+	} else {
+		host = "TODO"
 	}
 
-	routerLog.Error("Reverse: Failed to find controller for reverse route", "action", action, "arguments", argValues)
-	err = errors.New("Reverse: Failed to find controller for reverse route")
+	method := route.Method
+	star := false
+	if route.Method == "*" {
+		method = "GET"
+		star = true
+	}
+
+	log.Debugf("Reversing action %s to %s Using Route %#v", action, urlPath, route)
+
+	ad = &ActionDefinition{
+		URL:    urlPath,
+		Method: method,
+		Star:   star,
+		Action: action,
+		Args:   argValues,
+		Host:   host,
+	}
 	return
 }
 
@@ -771,6 +1145,20 @@ func RouterFilter(c *Controller, fc []Filter) {
 	// Figure out the Controller/Action
 	route := MainRouter.Route(c.Request)
 	if route == nil {
+		// The path may be routed for other methods
+		if allowed := MainRouter.AllowedMethods(c.Request.Host, c.Request.GetPath()); len(allowed) > 0 {
+			c.Response.Out.internalHeader.Set("Allow", strings.Join(allowed, ", "))
+			if c.Request.Method == "OPTIONS" {
+				c.Result = statusResult{http.StatusNoContent}
+				return
+			}
+			c.Response.Status = http.StatusMethodNotAllowed
+			c.Result = c.RenderError(&Error{
+				Title:       "Method Not Allowed",
+				Description: "Method " + c.Request.Method + " is not allowed for " + c.Request.GetPath() + " (valid: " + strings.Join(allowed, ", ") + ")",
+			})
+			return
+		}
 		c.Result = c.NotFound("No matching route found: " + c.Request.GetRequestURI())
 		return
 	}
@@ -789,6 +1177,12 @@ func RouterFilter(c *Controller, fc []Filter) {
 
 	// Add the route and fixed params to the Request Params.
 	c.Params.Route = route.Params
+	if len(route.Filters) > 0 {
+		c.Args[routeFiltersArg] = route.Filters
+	}
+	if route.Handler != nil {
+		c.Args[routeHandlerArg] = route.Handler
+	}
 	// Assign logger if from module
 	if c.Type.ModuleSource != nil && c.Type.ModuleSource != appModule {
 		c.Log = c.Type.ModuleSource.Log.New("ip", c.ClientIP,
@@ -854,8 +1248,7 @@ func HTTPMethodOverride(c *Controller, fc []Filter) {
 
 func init() {
 	OnAppStart(func() {
-		MainRouter = NewRouter(filepath.Join(BasePath, "conf", "routes"))
-		err := MainRouter.Refresh()
+		err := mainRouter().Refresh()
 		if MainWatcher != nil && Config.BoolDefault("watch.routes", true) {
 			MainWatcher.Listen(MainRouter, MainRouter.path)
 		} else if err != nil {
@@ -0,0 +1,183 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RouteFilters holds the filters the groups of the routes files refer to by
+// name. The application registers them before the routes are loaded, e.g. in
+// an init function:
+//
+//	revel.RouteFilters["auth"] = AuthFilter
+var RouteFilters = map[string]Filter{}
+
+// The controller argument holding the filters of the route groups.
+const routeFiltersArg = "route.filters"
+
+// RouteGroup adds routes sharing a path prefix and filters to a router. The
+// filters run before the action, after those of revel.Filters and the
+// FilterConfigurator, e.g.
+//
+//	admin := revel.MainRouter.Group("/admin", AuthFilter)
+//	admin.Add("GET", "/", "Admin.Index")
+//	admin.Add("GET", "/users/{id:int}", "Admin.User", revel.RouteName("admin.user"))
+//
+// The routes files declare groups with a block, the filters being the names
+// of RouteFilters, and name the routes with "as":
+//
+//	group /admin auth {
+//	    GET  /                 Admin.Index
+//	    GET  /users/{id:int}   Admin.User     as admin.user
+//	}
+type RouteGroup struct {
+	router  *Router
+	prefix  string   // The path prefix, e.g. /admin or {tenant}.example.com/admin
+	filters []Filter // The filters of the group, outermost first
+}
+
+// RouteOption sets a property of a route added in Go, before the route is
+// added to the router.
+type RouteOption func(route *Route)
+
+// RouteName names the route, Router.Reverse and the url template function
+// accept the name in place of the action. The names must be unique.
+func RouteName(name string) RouteOption {
+	return func(route *Route) {
+		route.Name = name
+	}
+}
+
+// A group opened in a routes file.
+type routeGroupLine struct {
+	prefix  string
+	filters []Filter
+}
+
+// Groups:
+// 1: prefix
+// 2: filter names
+var routeGroupPattern = regexp.MustCompile(`^group[ \t]+([^ \t/]*/[^ \t]*?)(?:[ \t]+([^{]*?))?[ \t]*\{$`)
+
+// The name ending a route line, e.g. "GET / App.Index as home"
+var routeNamePattern = regexp.MustCompile(`[ \t]+as[ \t]+([^ \t()]+)$`)
+
+// Group returns a group adding routes under the prefix, which run the filters
+// before their actions.
+func (router *Router) Group(prefix string, filters ...Filter) *RouteGroup {
+	return &RouteGroup{router: router, prefix: prefix, filters: filters}
+}
+
+// Group returns a group nested in this one, the prefixes and filters add up.
+func (group *RouteGroup) Group(prefix string, filters ...Filter) *RouteGroup {
+	return &RouteGroup{
+		router:  group.router,
+		prefix:  joinRoutePath(group.prefix, prefix),
+		filters: append(append([]Filter{}, group.filters...), filters...),
+	}
+}
+
+// Add adds a route to the router for the method and the path under the prefix
+// of the group. The action may have fixed arguments, as in the routes files,
+// e.g. `Static.Serve("public")`. The route is returned, or an error if it could
+// not be added.
+func (group *RouteGroup) Add(method, path, action string, options ...RouteOption) (*Route, error) {
+	fixedArgs := ""
+	if i := strings.Index(action, "("); i != -1 && strings.HasSuffix(action, ")") {
+		action, fixedArgs = action[:i], action[i+1:len(action)-1]
+	}
+	route := NewRoute(appModule, method, group.routePath(path), action, fixedArgs, "", 0)
+	route.Filters = append([]Filter{}, group.filters...)
+	for _, option := range options {
+		option(route)
+	}
+	if err := group.router.addRoute(route); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// AddHandler adds a route to the router for the method and the path under the
+// prefix of the group, which is handled by the function rather than an
+// action, see AddRouteHandler.
+func (group *RouteGroup) AddHandler(method, path string, handler func(c *Controller) Result, options ...RouteOption) (*Route, error) {
+	route := newHandlerRoute(method, group.routePath(path), handler)
+	route.Filters = append([]Filter{}, group.filters...)
+	for _, option := range options {
+		option(route)
+	}
+	if err := group.router.addRoute(route); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// Returns the path of a route of the group, the host of the group first.
+func (group *RouteGroup) routePath(path string) string {
+	host, prefix := splitRouteHost(group.prefix)
+	if routeHost, routePath := splitRouteHost(path); routeHost != "" {
+		host, path = routeHost, routePath
+	}
+	return host + AppRoot + joinRoutePath(prefix, path)
+}
+
+// RouteByName returns the route with the name, nil if there is none.
+func (router *Router) RouteByName(name string) *Route {
+	router.lock.RLock()
+	defer router.lock.RUnlock()
+	return router.names[name]
+}
+
+// Parses the line opening a group, found is false if the line is not one.
+func parseRouteGroupLine(line string) (group routeGroupLine, found bool, err error) {
+	if !strings.HasPrefix(line, "group ") && !strings.HasPrefix(line, "group\t") {
+		return
+	}
+	matches := routeGroupPattern.FindStringSubmatch(line)
+	if matches == nil {
+		err = fmt.Errorf("invalid group %q, expected \"group /prefix [filter, ...] {\"", line)
+		return
+	}
+	if err = checkRoutePath(matches[1]); err != nil {
+		return
+	}
+	group.prefix = matches[1]
+	for _, name := range splitConfigList(matches[2]) {
+		filter, ok := RouteFilters[name]
+		if !ok {
+			err = fmt.Errorf("unknown filter %s of group %s, add it to revel.RouteFilters", name, group.prefix)
+			return
+		}
+		group.filters = append(group.filters, filter)
+	}
+	return group, true, nil
+}
+
+// Returns the route line without the name ending it, and the name.
+func parseRouteName(line string) (string, string) {
+	if matches := routeNamePattern.FindStringSubmatchIndex(line); matches != nil {
+		return line[:matches[0]], line[matches[2]:matches[3]]
+	}
+	return line, ""
+}
+
+// Prepends the filters of the enclosing groups to those of the routes.
+func withRouteFilters(routes []*Route, filters []Filter) []*Route {
+	if len(filters) == 0 {
+		return routes
+	}
+	for _, route := range routes {
+		route.Filters = append(append([]Filter{}, filters...), route.Filters...)
+	}
+	return routes
+}
+
+// Joins the prefix and the path, without a double slash.
+func joinRoutePath(prefix, path string) string {
+	return strings.TrimSuffix(prefix, "/") + path
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Splits the host off the path of a route, e.g. "{tenant}.example.com/users"
+// into "{tenant}.example.com" and "/users".
+func splitRouteHost(path string) (host, rest string) {
+	if i := strings.Index(path, "/"); i > 0 {
+		return path[:i], path[i:]
+	}
+	return "", path
+}
+
+// Compiles the host of a route, returning the names of its parameters in
+// order. The routes are scoped to a host by writing it before the path, a
+// parameter being a whole label, e.g.
+//
+//	GET   {tenant}.example.com/           Tenants.Index
+//	GET   {tenant:slug}.example.com/users Tenants.Users
+//	GET   /                               Application.Index
+//
+// The parameters of the host land in Params.Route, and the routes of a host
+// are reversed to absolute URLs. The port of the request is ignored, unless
+// the host of the route has one. A route without a host matches any host,
+// the routes of the same path are tried in the order of the routes file.
+func parseRouteHost(host string) (*regexp.Regexp, []string, error) {
+	if host == "" {
+		return nil, nil, nil
+	}
+	var params []string
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(label, "{") {
+			labels[i] = regexp.QuoteMeta(label)
+			continue
+		}
+		name, pattern, rest, err := parseRouteParameter(label)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pattern == "" {
+			pattern = `[^.]+`
+		}
+		labels[i] = fmt.Sprintf("(?P<p%d>%s)%s", len(params), pattern, regexp.QuoteMeta(rest))
+		params = append(params, name)
+	}
+	re, err := regexp.Compile(`(?i)^` + strings.Join(labels, `\.`) + `$`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid host %s: %s", host, err)
+	}
+	return re, params, nil
+}
+
+// Returns an error if the host or the parameters of the path of a route are
+// invalid.
+func checkRoutePath(path string) error {
+	host, path := splitRouteHost(path)
+	if _, _, err := parseRouteHost(host); err != nil {
+		return err
+	}
+	_, _, err := parseRouteConstraints(path)
+	return err
+}
+
+// Returns the values of the host parameters, false if the host does not match
+// the host of the route.
+func (route *Route) matchHost(host string) (values []string, ok bool) {
+	if route.host == nil {
+		return nil, true
+	}
+	matches := route.host.FindStringSubmatch(host)
+	if matches == nil {
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			matches = route.host.FindStringSubmatch(hostname)
+		}
+	}
+	if matches == nil {
+		return nil, false
+	}
+	for i := range route.hostParams {
+		values = append(values, matches[route.host.SubexpIndex(fmt.Sprintf("p%d", i))])
+	}
+	return values, true
+}
+
+// Returns the host of the route for the arguments, those filling the host
+// parameters are removed.
+func (route *Route) reverseHost(argValues map[string]string) (string, error) {
+	labels := strings.Split(route.Host, ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(label, "{") {
+			continue
+		}
+		name, _, rest, err := parseRouteParameter(label)
+		if err != nil {
+			return "", err
+		}
+		value, found := argValues[name]
+		if !found {
+			return "", fmt.Errorf("missing host argument %s", name)
+		}
+		labels[i] = value + rest
+		delete(argValues, name)
+	}
+	return strings.Join(labels, "."), nil
+}
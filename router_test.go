@@ -7,6 +7,7 @@ package revel
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -43,6 +44,13 @@ var routeTestCases = map[string]*Route{
 		FixedParams: []string{},
 	},
 
+	`get /app/{appId:int}/{name:[a-z]{2,}}.json Application.Show`: {
+		Method:      "GET",
+		Path:        `/app/:appId/:name.json`,
+		Action:      "Application.Show",
+		FixedParams: []string{},
+	},
+
 	`get /app-wild/*appId/ Application.WildShow`: {
 		Method:      "GET",
 		Path:        `/app-wild/*appId/`,
@@ -654,6 +662,134 @@ func BenchmarkRouterFilter(b *testing.B) {
 	}
 }
 
+const constrainedTestRoutes = `
+GET   /app/{id:int}           Application.Show
+GET   /app/{name:[a-z-]+}     Application.List
+GET   /app/{uuid}/edit        Application.Update
+`
+
+func TestRouteConstraints(t *testing.T) {
+	initControllers()
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes(appModule, "", "", constrainedTestRoutes, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("updateTree failed: %s", err)
+	}
+
+	for path, expected := range map[string]string{
+		"/app/123":    "show",
+		"/app/-1":     "show",
+		"/app/my-app": "list",
+		"/app/My_App": "",
+		"/app/1.5":    "",
+		"/app/7a1e6f02-2b5c-4f8e-9d3a-0c6b4e2f9a11/edit": "update",
+		"/app/123/edit": "",
+	} {
+		routeMatch := router.route("GET", "", path)
+		if expected == "" {
+			if routeMatch != nil {
+				t.Errorf("Expected no route for %s, got %#v", path, routeMatch)
+			}
+			continue
+		}
+		if routeMatch == nil || routeMatch == notFound {
+			t.Errorf("Expected a route for %s", path)
+			continue
+		}
+		eq(t, "MethodName "+path, routeMatch.MethodName, expected)
+	}
+	eq(t, "Params", router.route("GET", "", "/app/my-app").Params["name"][0], "my-app")
+	eq(t, "Allowed /app/My_App", len(router.AllowedMethods("", "/app/My_App")), 0)
+
+	if _, err := parseRoutes(appModule, "", "", "GET /app/{id:[0-9} Application.Show", false); err == nil {
+		t.Error("Expected an error for an invalid constraint")
+	}
+}
+
+const fallbackTestRoutes = `
+GET   /app/{id:int}   Application.Show
+GET   /app/*path      Application.List
+`
+
+func TestRouteConstraintsFallback(t *testing.T) {
+	initControllers()
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes(appModule, "", "", fallbackTestRoutes, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("updateTree failed: %s", err)
+	}
+
+	// The tree finds /app/{id} for a single element, the catch-all is tried
+	// once its constraint fails
+	for _, test := range []struct{ method, path, expected, param string }{
+		{"GET", "/app/123", "show", "id"},
+		{"GET", "/app/my-app", "list", "path"},
+		{"HEAD", "/app/my-app", "list", "path"},
+		{"GET", "/app/my/app", "list", "path"},
+	} {
+		routeMatch := router.route(test.method, "", test.path)
+		if routeMatch == nil || routeMatch == notFound {
+			t.Errorf("Expected a route for %s %s", test.method, test.path)
+			continue
+		}
+		eq(t, "MethodName "+test.path, routeMatch.MethodName, test.expected)
+		eq(t, "Param "+test.param, len(routeMatch.Params[test.param]), 1)
+	}
+	eq(t, "Allowed /app/my-app", strings.Join(router.AllowedMethods("", "/app/my-app"), ", "), "GET, HEAD, OPTIONS")
+}
+
+func TestAllowedMethods(t *testing.T) {
+	initControllers()
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes(appModule, "", "", TestRoutes, false)
+	if err := router.updateTree(); err != nil {
+		t.Errorf("updateTree failed: %s", err)
+	}
+
+	eq(t, "Allowed /", strings.Join(router.AllowedMethods("", "/"), ", "), "GET, HEAD, OPTIONS")
+	allowed := strings.Join(router.AllowedMethods("", "/app/123"), ", ")
+	for _, method := range []string{"POST", "PURGE", "TRACE"} {
+		if !strings.Contains(allowed, method) {
+			t.Errorf("Expected %s in the allowed methods, got %s", method, allowed)
+		}
+	}
+	if strings.Contains(allowed, "PUT") {
+		t.Errorf("Expected no PUT in the allowed methods, got %s", allowed)
+	}
+	// An explicit 404 is not allowed
+	eq(t, "Allowed /favicon.ico", len(router.AllowedMethods("", "/favicon.ico")), 0)
+}
+
+func TestRouterFilterMethodNotAllowed(t *testing.T) {
+	startFakeBookingApp()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/hotels", nil)
+	c := NewTestController(resp, req)
+	c.Params = &Params{}
+	RouterFilter(c, NilChain)
+	c.Result.Apply(c.Request, c.Response)
+	eq(t, "Status", resp.Code, http.StatusMethodNotAllowed)
+	eq(t, "Allow", resp.Header().Get("Allow"), "GET, HEAD, OPTIONS")
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/hotels", nil)
+	c = NewTestController(resp, req)
+	c.Params = &Params{}
+	RouterFilter(c, NilChain)
+	c.Result.Apply(c.Request, c.Response)
+	eq(t, "Status", resp.Code, http.StatusNoContent)
+	eq(t, "Allow", resp.Header().Get("Allow"), "GET, HEAD, OPTIONS")
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/missing", nil)
+	c = NewTestController(resp, req)
+	c.Params = &Params{}
+	RouterFilter(c, NilChain)
+	c.Result.Apply(c.Request, c.Response)
+	eq(t, "Status", resp.Code, http.StatusNotFound)
+}
+
 func TestOverrideMethodFilter(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/hotels/3", strings.NewReader("_method=put"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
@@ -673,3 +809,222 @@ func eq(t *testing.T, name string, a, b interface{}) bool {
 	}
 	return true
 }
+
+const groupedTestRoutes = `
+GET   /                        Application.Index    as home
+group /admin admin {
+    GET   /                    Application.List     as admin.index
+    group /apps/{id:int} {
+        GET   /                Application.Show     as admin.app
+        POST  /                Application.Save
+    }
+}
+`
+
+func TestRouteGroups(t *testing.T) {
+	initControllers()
+	var order []string
+	RouteFilters["admin"] = func(c *Controller, fc []Filter) {
+		order = append(order, "admin")
+		fc[0](c, fc[1:])
+	}
+	defer delete(RouteFilters, "admin")
+
+	router := NewRouter("")
+	var err *Error
+	if router.Routes, err = parseRoutes(appModule, "", "", groupedTestRoutes, false); err != nil {
+		t.Fatalf("parseRoutes failed: %s", err)
+	}
+	if err = router.updateTree(); err != nil {
+		t.Fatalf("updateTree failed: %s", err)
+	}
+	eq(t, "Paths", strings.Join([]string{router.Routes[1].Path, router.Routes[2].Path, router.Routes[3].Path}, " "), "/admin/ /admin/apps/:id/ /admin/apps/:id/")
+	eq(t, "Filters /", len(router.route("GET", "", "/").Filters), 0)
+	eq(t, "Filters /admin/apps/5", len(router.route("POST", "", "/admin/apps/5").Filters), 1)
+	eq(t, "Constraint of the group", router.route("GET", "", "/admin/apps/abc"), (*RouteMatch)(nil))
+
+	eq(t, "Reverse home", router.Reverse("home", map[string]string{}).URL, "/")
+	eq(t, "Reverse admin.app", router.Reverse("admin.app", map[string]string{"id": "5"}).URL, "/admin/apps/5/")
+	oldRouter := MainRouter
+	MainRouter = router
+	defer func() { MainRouter = oldRouter }()
+	if url, err := ReverseURL("admin.app", 7); err != nil || url != "/admin/apps/7/" {
+		t.Errorf("Expected the url of the named route, got %s %v", url, err)
+	}
+
+	// The filters of the group run before the action
+	c := NewTestController(nil, &http.Request{Method: "GET", URL: &url.URL{Path: "/"}})
+	c.Args[routeFiltersArg] = router.route("GET", "", "/admin").Filters
+	FilterConfiguringFilter(c, []Filter{
+		func(c *Controller, fc []Filter) { order = append(order, "params"); fc[0](c, fc[1:]) },
+		func(c *Controller, fc []Filter) { order = append(order, "action") },
+	})
+	eq(t, "Filter order", strings.Join(order, ", "), "params, admin, action")
+
+	// The routes may be grouped in Go too
+	api := router.Group("/api", NilFilter).Group("/v1")
+	if _, err := api.Add("GET", "/apps/{id:int}", "Application.Show", RouteName("api.app")); err != nil {
+		t.Fatalf("Add failed: %s", err)
+	}
+	if routeMatch := router.route("GET", "", "/api/v1/apps/3"); routeMatch == nil || len(routeMatch.Filters) != 1 {
+		t.Errorf("Expected the route added to the group, got %#v", routeMatch)
+	}
+	eq(t, "Reverse api.app", router.Reverse("api.app", map[string]string{"id": "3"}).URL, "/api/v1/apps/3")
+
+	for _, routes := range []string{
+		"group /admin {\nGET / Application.List",
+		"GET / Application.List\n}",
+		"group /admin unknown {\n}",
+	} {
+		if _, err := parseRoutes(appModule, "", "", routes, false); err == nil {
+			t.Errorf("Expected an error for %q", routes)
+		}
+	}
+	router.Routes, _ = parseRoutes(appModule, "", "", "GET / Application.Index as home\nGET /list Application.List as home", false)
+	if err := router.updateTree(); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+}
+
+func TestAddRoute(t *testing.T) {
+	startFakeBookingApp()
+	defer func() {
+		MainRouter.added = nil
+		_ = MainRouter.Refresh()
+	}()
+
+	if _, err := AddRoute("GET", "/extra/{id:int}", "Hotels.Show", RouteName("extra")); err != nil {
+		t.Fatalf("AddRoute failed: %s", err)
+	}
+	_, err := AddRouteHandler("GET", "/health/{check:alpha}", func(c *Controller) Result {
+		return c.RenderText("ok " + c.Params.Route.Get("check"))
+	}, RouteName("health"))
+	if err != nil {
+		t.Fatalf("AddRouteHandler failed: %s", err)
+	}
+	// A route which does not fit in the tree, or whose name is taken, is not kept
+	if _, err = AddRoute("GET", "/broken//path", "Hotels.Show"); err == nil {
+		t.Error("Expected an error for a route with an empty path element")
+	}
+	if _, err = AddRoute("GET", "/other/{id:int}", "Hotels.Show", RouteName("extra")); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+	eq(t, "Added routes", len(MainRouter.added), 2)
+	// The routes are kept when the routes file is refreshed
+	if err := MainRouter.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %s", err)
+	}
+
+	if routeMatch := MainRouter.route("GET", "", "/extra/3"); routeMatch == nil || routeMatch.MethodName != "show" {
+		t.Errorf("Expected the added route, got %#v", routeMatch)
+	}
+	eq(t, "Reverse extra", MainRouter.Reverse("extra", map[string]string{"id": "3"}).URL, "/extra/3")
+	eq(t, "Reverse health", MainRouter.Reverse("health", map[string]string{"check": "db"}).URL, "/health/db")
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health/db", nil)
+	c := NewTestController(resp, req)
+	c.Params = &Params{}
+	RouterFilter(c, []Filter{ActionInvoker})
+	c.Result.Apply(c.Request, c.Response)
+	eq(t, "Handler response", resp.Body.String(), "ok db")
+}
+
+func TestAddRouteWhileRouting(t *testing.T) {
+	startFakeBookingApp()
+	defer func() {
+		MainRouter.added = nil
+		_ = MainRouter.Refresh()
+	}()
+
+	done := make(chan struct{})
+	missed := make(chan string, 1)
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if routeMatch := MainRouter.route("GET", "", "/hotels/3"); routeMatch == nil || routeMatch.MethodName != "show" {
+				missed <- fmt.Sprintf("%#v", routeMatch)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		AddRouteHandler("GET", fmt.Sprintf("/added/%d", i), func(c *Controller) Result {
+			return c.RenderText("ok")
+		})
+	}
+	<-done
+	select {
+	case routeMatch := <-missed:
+		t.Errorf("Expected the route while routes are added, got %s", routeMatch)
+	default:
+	}
+	if routeMatch := MainRouter.route("GET", "", "/added/49"); routeMatch == nil {
+		t.Error("Expected the added route")
+	}
+}
+
+const hostTestRoutes = `
+GET   {tenant}.example.com/          Application.Show     as tenant.home
+GET   /                              Application.Index
+GET   {tenant:int}.example.com/apps  Application.List
+GET   admin.example.com:9000/login   Application.Save
+group {tenant}.example.com/admin {
+    GET   /users                     Application.Update
+}
+`
+
+func TestHostRouting(t *testing.T) {
+	initControllers()
+	router := NewRouter("")
+	var err *Error
+	if router.Routes, err = parseRoutes(appModule, "", "", hostTestRoutes, false); err != nil {
+		t.Fatalf("parseRoutes failed: %s", err)
+	}
+	if err = router.updateTree(); err != nil {
+		t.Fatalf("updateTree failed: %s", err)
+	}
+
+	for _, test := range []struct{ host, path, method, tenant string }{
+		{"acme.example.com", "/", "show", "acme"},
+		{"ACME.example.com:9000", "/", "show", "ACME"},
+		{"example.com", "/", "index", ""},
+		{"localhost", "/", "index", ""},
+		{"12.example.com", "/apps", "list", "12"},
+		{"acme.example.com", "/apps", "", ""},
+		{"admin.example.com:9000", "/login", "save", ""},
+		{"admin.example.com", "/login", "", ""},
+		{"acme.example.com", "/admin/users", "update", "acme"},
+		{"example.com", "/admin/users", "", ""},
+	} {
+		routeMatch := router.route("GET", test.host, test.path)
+		if test.method == "" {
+			if routeMatch != nil {
+				t.Errorf("Expected no route for %s%s, got %#v", test.host, test.path, routeMatch)
+			}
+			continue
+		}
+		if routeMatch == nil || routeMatch == notFound {
+			t.Errorf("Expected a route for %s%s", test.host, test.path)
+			continue
+		}
+		eq(t, "MethodName "+test.host+test.path, routeMatch.MethodName, test.method)
+		eq(t, "Tenant "+test.host+test.path, url.Values(routeMatch.Params).Get("tenant"), test.tenant)
+	}
+	eq(t, "Allowed acme.example.com/apps", len(router.AllowedMethods("acme.example.com", "/apps")), 0)
+
+	ad := router.Reverse("tenant.home", map[string]string{"tenant": "acme"})
+	eq(t, "Reverse tenant.home", ad.URL, "http://acme.example.com/")
+	eq(t, "Reverse host", ad.Host, "acme.example.com")
+	eq(t, "Reverse Application.List", router.Reverse("Application.List", map[string]string{"tenant": "12"}).URL, "http://12.example.com/apps")
+	if _, err := router.ReverseError("tenant.home", map[string]string{}, nil); err == nil {
+		t.Error("Expected an error for a missing host argument")
+	}
+
+	oldRouter := MainRouter
+	MainRouter = router
+	defer func() { MainRouter = oldRouter }()
+	if url, err := ReverseURL("tenant.home", "acme"); err != nil || url != "http://acme.example.com/" {
+		t.Errorf("Expected the url of the tenant, got %s %v", url, err)
+	}
+}
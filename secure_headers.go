@@ -0,0 +1,165 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The view argument holding the CSP nonce of the request.
+const CSPNonceViewArg = "cspNonce"
+
+var (
+	cspLog = RevelLog.New("section", "csp")
+
+	// The headers set by the SecureHeadersFilter, the placeholder {nonce} in
+	// the values is replaced by the nonce of the request
+	secureHeaders map[string]string
+	// The path accepting the CSP violation reports, set via security.csp.report.path, empty if disabled
+	cspReportPath string
+)
+
+func init() {
+	OnAppStart(initSecureHeaders)
+}
+
+// Reads the security headers from the configuration.
+func initSecureHeaders() {
+	secureHeaders = map[string]string{}
+	for _, header := range []struct{ name, key, defaultValue string }{
+		{"Strict-Transport-Security", "security.hsts", "max-age=31536000; includeSubDomains"},
+		{"X-Frame-Options", "security.frame.options", "DENY"},
+		{"X-Content-Type-Options", "security.content.type.options", "nosniff"},
+		{"Referrer-Policy", "security.referrer.policy", "strict-origin-when-cross-origin"},
+		{"Permissions-Policy", "security.permissions.policy", ""},
+	} {
+		if value := Config.StringDefault(header.key, header.defaultValue); value != "" {
+			secureHeaders[header.name] = value
+		}
+	}
+
+	csp := Config.StringDefault("security.csp",
+		"default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'")
+	cspReportPath = Config.StringDefault("security.csp.report.path", "/@csp-report")
+	if csp == "" {
+		cspReportPath = ""
+		return
+	}
+	if cspReportPath != "" {
+		csp += "; report-uri " + cspReportPath
+	}
+	if Config.BoolDefault("security.csp.reportonly", false) {
+		secureHeaders["Content-Security-Policy-Report-Only"] = csp
+	} else {
+		secureHeaders["Content-Security-Policy"] = csp
+	}
+}
+
+// SecureHeadersFilter sets the security headers on the responses, an action
+// may override them. Add it to revel.Filters before the RouterFilter, so it
+// answers the report path. The headers are configured in app.conf, an empty
+// value removes the header:
+//
+//	security.hsts                  Strict-Transport-Security (default "max-age=31536000; includeSubDomains")
+//	security.frame.options         X-Frame-Options (default "DENY")
+//	security.content.type.options  X-Content-Type-Options (default "nosniff")
+//	security.referrer.policy       Referrer-Policy (default "strict-origin-when-cross-origin")
+//	security.permissions.policy    Permissions-Policy
+//	security.csp                   Content-Security-Policy, allowing only the scripts and styles of the app by default
+//	security.csp.reportonly        Send Content-Security-Policy-Report-Only instead, the policy is reported but not enforced
+//	security.csp.report.path       The path accepting the violation reports (default "/@csp-report"), empty to disable
+//
+// Each request is given a nonce, which replaces {nonce} in the policy. The
+// inline scripts of a template are allowed with it:
+//
+//	<script nonce="{{.cspNonce}}">...</script>
+//
+// The violation reports posted by the browsers to the report path are
+// logged as warnings in the "csp" section.
+func SecureHeadersFilter(c *Controller, fc []Filter) {
+	if cspReportPath != "" && c.Request.GetPath() == cspReportPath {
+		if c.Request.Method == "POST" {
+			logCSPReport(c)
+			c.Result = statusResult{http.StatusNoContent}
+		} else {
+			c.Response.Out.internalHeader.Set("Allow", "POST")
+			c.Result = statusResult{http.StatusMethodNotAllowed}
+		}
+		return
+	}
+
+	nonce := newCSPNonce()
+	c.ViewArgs[CSPNonceViewArg] = nonce
+	for name, value := range secureHeaders {
+		c.Response.Out.internalHeader.Set(name, strings.Replace(value, "{nonce}", nonce, -1))
+	}
+	fc[0](c, fc[1:])
+}
+
+// CSPNonce returns the nonce of the request set by the SecureHeadersFilter,
+// e.g. to add it to the scripts of a result rendered without a template.
+func CSPNonce(c *Controller) string {
+	nonce, _ := c.ViewArgs[CSPNonceViewArg].(string)
+	return nonce
+}
+
+// Returns a new random nonce.
+func newCSPNonce() string {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		cspLog.Error("newCSPNonce: Failed to generate nonce", "error", err)
+	}
+	return base64.StdEncoding.EncodeToString(nonce)
+}
+
+// Logs the violations of a report, sent either in the report-uri format or
+// the Reporting API format.
+func logCSPReport(c *Controller) {
+	body, err := ioutil.ReadAll(io.LimitReader(c.Request.GetBody(), 64<<10))
+	if err != nil {
+		cspLog.Error("logCSPReport: Failed to read report", "error", err)
+		return
+	}
+
+	var violations []map[string]interface{}
+	var report struct {
+		Report map[string]interface{} `json:"csp-report"`
+	}
+	var reports []struct {
+		Type string                 `json:"type"`
+		Body map[string]interface{} `json:"body"`
+	}
+	if err = json.Unmarshal(body, &report); err == nil && report.Report != nil {
+		violations = append(violations, report.Report)
+	} else if err = json.Unmarshal(body, &reports); err == nil {
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				violations = append(violations, report.Body)
+			}
+		}
+	} else {
+		cspLog.Warn("logCSPReport: Invalid report", "error", err)
+		return
+	}
+
+	for _, violation := range violations {
+		context := []interface{}{"remote", c.ClientIP, "user-agent", c.Request.GetHttpHeader("User-Agent")}
+		for _, field := range []string{
+			"document-uri", "documentURL", "violated-directive", "effective-directive", "effectiveDirective",
+			"blocked-uri", "blockedURL", "source-file", "sourceFile", "line-number", "lineNumber", "disposition",
+		} {
+			if value, found := violation[field]; found {
+				context = append(context, field, value)
+			}
+		}
+		cspLog.Warn("Content security policy violation", context...)
+	}
+}
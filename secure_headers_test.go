@@ -0,0 +1,83 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Runs the request through the SecureHeadersFilter, returning the response
+// and the nonce seen by the action.
+func requestSecureHeaders(req *http.Request) (*httptest.ResponseRecorder, string) {
+	resp := httptest.NewRecorder()
+	c := NewTestController(resp, req)
+	nonce := ""
+	SecureHeadersFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		nonce = CSPNonce(c)
+		c.Result = c.RenderText("ok")
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	return resp, nonce
+}
+
+func TestSecureHeadersFilter(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("security.frame.options", "SAMEORIGIN")
+	Config.SetOption("security.hsts", "")
+	defer func() {
+		Config.SetOption("security.frame.options", "DENY")
+		Config.SetOption("security.hsts", "max-age=31536000; includeSubDomains")
+		initSecureHeaders()
+	}()
+	initSecureHeaders()
+
+	req, _ := http.NewRequest("GET", "http://localhost/hotels", nil)
+	resp, nonce := requestSecureHeaders(req)
+	if resp.Header().Get("X-Frame-Options") != "SAMEORIGIN" || resp.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected the configured headers, got %v", resp.Header())
+	}
+	if _, found := resp.Header()["Strict-Transport-Security"]; found {
+		t.Error("Expected an empty setting to remove the header")
+	}
+	csp := resp.Header().Get("Content-Security-Policy")
+	if nonce == "" || !strings.Contains(csp, "script-src 'self' 'nonce-"+nonce+"'") || !strings.HasSuffix(csp, "; report-uri /@csp-report") {
+		t.Errorf("Expected the nonce %q in the policy, got %q", nonce, csp)
+	}
+	if _, next := requestSecureHeaders(req); next == nonce {
+		t.Error("Expected a nonce per request")
+	}
+
+	Config.SetOption("security.csp.reportonly", "true")
+	defer Config.SetOption("security.csp.reportonly", "false")
+	initSecureHeaders()
+	resp, _ = requestSecureHeaders(req)
+	if resp.Header().Get("Content-Security-Policy") != "" || resp.Header().Get("Content-Security-Policy-Report-Only") == "" {
+		t.Errorf("Expected the report only policy, got %v", resp.Header())
+	}
+}
+
+func TestSecureHeadersFilterReport(t *testing.T) {
+	startFakeBookingApp()
+	initSecureHeaders()
+
+	for _, body := range []string{
+		`{"csp-report":{"document-uri":"http://localhost/","violated-directive":"script-src","blocked-uri":"inline"}}`,
+		`[{"type":"csp-violation","body":{"documentURL":"http://localhost/","effectiveDirective":"script-src"}}]`,
+	} {
+		req, _ := http.NewRequest("POST", "http://localhost/@csp-report", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/csp-report")
+		if resp, _ := requestSecureHeaders(req); resp.Code != http.StatusNoContent {
+			t.Errorf("Expected the report to be accepted, got %d", resp.Code)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost/@csp-report", nil)
+	if resp, _ := requestSecureHeaders(req); resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "POST" {
+		t.Errorf("Expected a 405 for a GET, got %d", resp.Code)
+	}
+}
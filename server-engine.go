@@ -8,6 +8,7 @@ import (
 	"errors"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/url"
 	"strings"
 	"time"
@@ -116,6 +117,24 @@ type (
 		Port        int                 // The port
 		HTTPMuxList ServerMuxList       // The HTTPMux
 		Callback    func(ServerContext) // The ServerContext callback endpoint
+		HTTP2       EngineHTTP2         // The HTTP/2 options
+		Listeners   []*EngineListener   // The listeners to serve on, the first matches the Network and Address
+	}
+
+	// A listener the engine serves on.
+	EngineListener struct {
+		Network  string       // The network, e.g. "tcp", "unix"
+		Address  string       // The address, e.g. "localhost:9000", "/tmp/app.socket"
+		Name     string       // The name of the listener, passed in by LISTEN_FDNAMES for activated sockets
+		Listener net.Listener // An already open listener (socket activation), nil to listen on the Network and Address
+	}
+
+	// The HTTP/2 options passed into the engine.
+	EngineHTTP2 struct {
+		Enabled              bool          // True to negotiate HTTP/2 on TLS connections
+		Cleartext            bool          // True to accept HTTP/2 without TLS (h2c)
+		MaxConcurrentStreams uint32        // The maximum concurrent streams per connection, 0 for the default
+		IdleTimeout          time.Duration // The time before an idle connection is closed, 0 for the default
 	}
 
 	// An empty server engine.
@@ -128,11 +128,55 @@ func InitServerEngine(port int, serverEngine string) {
 			Network:  network,
 			Port:     port,
 			Callback: handleInternal,
+			HTTP2:    initEngineHTTP2(),
+		}
+		ServerEngineInit.Listeners = initEngineListeners(network, localAddress)
+		if first := ServerEngineInit.Listeners[0]; first.Listener != nil {
+			ServerEngineInit.Network, ServerEngineInit.Address = first.Network, first.Address
 		}
 	}
 	AddInitEventHandler(CurrentEngine.Event)
 }
 
+// Build the listeners from the application config, the additional listeners
+// are a comma separated list of network:address pairs in http.listeners
+// (e.g. "unix:/tmp/health.socket, tcp:localhost:9001"). Sockets passed in by
+// the service manager (LISTEN_FDS) replace the configured listeners.
+func initEngineListeners(network, address string) []*EngineListener {
+	if Config.BoolDefault("server.socket.activation", true) {
+		activated, err := activatedListeners(listenFdsStart)
+		if err != nil {
+			serverLogger.Fatal("InitServerEngine: Failed to use the activated sockets", "error", err)
+		}
+		if len(activated) > 0 {
+			serverLogger.Info("InitServerEngine: Using activated sockets", "count", len(activated))
+			return activated
+		}
+	}
+
+	listeners := []*EngineListener{{Network: network, Address: address}}
+	for _, listener := range strings.Split(Config.StringDefault("http.listeners", ""), ",") {
+		if listener = strings.TrimSpace(listener); listener == "" {
+			continue
+		}
+		parts := strings.SplitN(listener, ":", 2)
+		if len(parts) != 2 {
+			serverLogger.Fatal("InitServerEngine: Invalid http.listeners entry, expected network:address", "listener", listener)
+		}
+		listeners = append(listeners, &EngineListener{Network: parts[0], Address: parts[1]})
+	}
+	return listeners
+}
+
+// Build the HTTP/2 options from the application config.
+func initEngineHTTP2() (options EngineHTTP2) {
+	options.Enabled = Config.BoolDefault("server.http2", true)
+	options.Cleartext = Config.BoolDefault("server.http2.h2c", false)
+	options.MaxConcurrentStreams = uint32(Config.IntDefault("server.http2.maxstreams", 0))
+	options.IdleTimeout = ConfigDuration("server.http2.idle.timeout", 0)
+	return
+}
+
 // Initialize the controller stack for the application.
 func initControllerStack() {
 	RevelConfig.Controller.Reuse = Config.BoolDefault("revel.controller.reuse", true)
@@ -149,7 +193,9 @@ func initControllerStack() {
 	}
 }
 
-// Called to stop the server.
+// Called to stop the server. The engine stops accepting new connections and
+// drains the in-flight requests (up to server.shutdown.timeout) before Run
+// executes the shutdown hooks.
 func StopServer(value interface{}) EventResponse {
 	return RaiseEvent(ENGINE_SHUTDOWN_REQUEST, value)
 }
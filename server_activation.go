@@ -0,0 +1,67 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The first file descriptor passed in by the service manager, 0-2 are stdin, stdout and stderr.
+const listenFdsStart = 3
+
+// Returns the listeners for the sockets passed in by the service manager
+// (e.g. systemd socket activation, or a parent process during a zero downtime
+// restart). The protocol is described at
+// https://www.freedesktop.org/software/systemd/man/sd_listen_fds.html
+// The variables are unset so they are not inherited by child processes.
+func activatedListeners(firstFd int) (listeners []*EngineListener, err error) {
+	pid, count := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	if count == "" {
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	// The sockets were passed to another process
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		serverLogger.Warn("activatedListeners: LISTEN_PID does not match this process, ignoring", "pid", pid)
+		return
+	}
+	total, err := strconv.Atoi(count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q: %s", count, err)
+	}
+
+	for i := 0; i < total; i++ {
+		fd := firstFd + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		// The listener holds a duplicate of the descriptor
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("file descriptor %d (%s) is not a listening socket: %s", fd, name, err)
+		}
+		listeners = append(listeners, &EngineListener{
+			Network:  listener.Addr().Network(),
+			Address:  listener.Addr().String(),
+			Name:     name,
+			Listener: listener,
+		})
+	}
+	return
+}
@@ -1,7 +1,9 @@
 package revel
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"io"
 	"mime/multipart"
 	"net"
@@ -13,10 +15,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/revel/revel/utils"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/net/websocket"
 )
 
@@ -24,7 +30,7 @@ import (
 func init() {
 	AddInitEventHandler(func(typeOf Event, value interface{}) (responseOf EventResponse) {
 		if typeOf == REVEL_BEFORE_MODULES_LOADED {
-			RegisterServerEngine(GO_NATIVE_SERVER_ENGINE, func() ServerEngine { return &GoHttpServer{} })
+			RegisterServerEngine(GO_NATIVE_SERVER_ENGINE, func() ServerEngine { return newGoHttpServer() })
 		}
 		return
 	})
@@ -40,6 +46,18 @@ type GoHttpServer struct {
 	HttpMuxList          ServerMuxList
 	HasAppMux            bool
 	signalChan           chan os.Signal
+	ShutdownTimeout      time.Duration         // The time allowed to drain in-flight requests, set via server.shutdown.timeout
+	inFlight             sync.WaitGroup        // The requests (including websockets) currently being handled
+	shutdownOnce         sync.Once             // Ensures the server is only drained once
+	shutdownComplete     chan struct{}         // Closed when the shutdown has finished draining
+	http2Stats           *goHttp2Stats         // The HTTP/2 stream counters
+	TLSLoader            *TLSCertificateLoader // The certificates served when http.ssl is enabled
+}
+
+// The HTTP/2 stream counters, allocated separately to keep the 64 bit values aligned.
+type goHttp2Stats struct {
+	activeStreams int64 // The streams currently being handled
+	totalStreams  int64 // The streams handled since the server started
 }
 
 // Called to initialize the server with this EngineInit.
@@ -64,6 +82,10 @@ func (g *GoHttpServer) Init(init *EngineInit) {
 	sort.Sort(g.HttpMuxList)
 	g.HasAppMux = len(g.HttpMuxList) > 0
 	g.signalChan = make(chan os.Signal)
+	g.shutdownComplete = make(chan struct{})
+
+	// The drain timeout, app.cancel.timeout (in seconds) is honored for backwards compatibility
+	g.ShutdownTimeout = ConfigDuration("server.shutdown.timeout", time.Duration(Config.IntDefault("app.cancel.timeout", 60))*time.Second)
 
 	g.Server = &http.Server{
 		Addr:         init.Address,
@@ -71,33 +93,151 @@ func (g *GoHttpServer) Init(init *EngineInit) {
 		ReadTimeout:  time.Duration(Config.IntDefault("http.timeout.read", 0)) * time.Second,
 		WriteTimeout: time.Duration(Config.IntDefault("http.timeout.write", 0)) * time.Second,
 	}
+	if HTTPSsl {
+		g.configureTLS()
+	}
+	g.configureHTTP2(revelHandler)
+}
+
+// Configures the certificates served for TLS connections, the certificate
+// files are watched and reloaded unless http.sslwatch is false.
+func (g *GoHttpServer) configureTLS() {
+	loader, err := NewTLSCertificateLoader()
+	if err != nil {
+		serverLogger.Fatal("Init: Failed to load certificates", "error", err)
+	}
+	if Config.BoolDefault("http.sslwatch", true) {
+		loader.Watch()
+	}
+	g.TLSLoader = loader
+	g.Server.TLSConfig = &tls.Config{GetCertificate: loader.GetCertificate}
+}
+
+// Configures HTTP/2 on the server from the EngineInit options, when h2c is
+// enabled the handler is wrapped so cleartext HTTP/2 connections are accepted.
+func (g *GoHttpServer) configureHTTP2(handler http.Handler) {
+	if g.http2Stats == nil {
+		g.http2Stats = &goHttp2Stats{}
+	}
+	options := g.ServerInit.HTTP2
+	if !options.Enabled {
+		// A non nil empty map disables the automatic HTTP/2 upgrade on TLS connections
+		g.Server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	if !options.Enabled && !options.Cleartext {
+		return
+	}
+
+	h2server := &http2.Server{
+		MaxConcurrentStreams: options.MaxConcurrentStreams,
+		IdleTimeout:          options.IdleTimeout,
+	}
+	if options.Enabled {
+		if err := http2.ConfigureServer(g.Server, h2server); err != nil {
+			serverLogger.Error("Init: Failed to configure HTTP/2", "error", err)
+		}
+	}
+	if options.Cleartext {
+		g.Server.Handler = h2c.NewHandler(handler, h2server)
+	}
 }
 
 // Handler is assigned in the Init.
+// Start blocks until the server is stopped, if the server was stopped by a
+// shutdown request it does not return until the in-flight requests have drained.
 func (g *GoHttpServer) Start() {
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		serverLogger.Debugf("Start: Listening on %s...", g.Server.Addr)
-	}()
+	listeners := g.ServerInit.Listeners
+	if len(listeners) == 0 {
+		listeners = []*EngineListener{{Network: g.ServerInit.Network, Address: g.Server.Addr}}
+	}
+
+	// Every listener is served by the same http.Server, so a shutdown closes them all
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener *EngineListener) {
+			errs <- g.serve(listener)
+		}(listener)
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		// Stop the other listeners as well, rather than serving on part of them
+		serverLogger.Warn("Server exiting:", "error", err)
+		if err = g.Server.Close(); err != nil {
+			serverLogger.Error("Start: Failed to close server", "error", err)
+		}
+		return
+	}
+
+	// Serve returns as soon as the listeners are closed, wait for the drain to finish
+	<-g.shutdownComplete
+	serverLogger.Info("Server exiting")
+}
+
+// Serves the listener, opening it first if it was not passed in. When SSL is
+// enabled only TCP listeners may be served, it is standard to terminate SSL
+// upstream when using unix domain sockets.
+func (g *GoHttpServer) serve(engineListener *EngineListener) error {
+	listener := engineListener.Listener
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen(engineListener.Network, engineListener.Address); err != nil {
+			serverLogger.Fatal("Failed to listen:", "network", engineListener.Network, "address", engineListener.Address, "error", err)
+		}
+	}
+	serverLogger.Debugf("Start: Listening on %s %s...", listener.Addr().Network(), listener.Addr().String())
+
 	if HTTPSsl {
-		if g.ServerInit.Network != "tcp" {
+		if !strings.HasPrefix(listener.Addr().Network(), "tcp") {
 			// This limitation is just to reduce complexity, since it is standard
 			// to terminate SSL upstream when using unix domain sockets.
-			serverLogger.Fatal("SSL is only supported for TCP sockets. Specify a port to listen on.")
+			serverLogger.Fatal("SSL is only supported for TCP sockets. Specify a port to listen on.", "address", listener.Addr().String())
 		}
-		serverLogger.Fatal("Failed to listen:", "error",
-			g.Server.ListenAndServeTLS(HTTPSslCert, HTTPSslKey))
-	} else {
-		listener, err := net.Listen(g.ServerInit.Network, g.Server.Addr)
-		if err != nil {
-			serverLogger.Fatal("Failed to listen:", "error", err)
-		}
-		serverLogger.Warn("Server exiting:", "error", g.Server.Serve(listener))
+		// The certificates are served by the TLSConfig
+		return g.Server.ServeTLS(listener, "", "")
 	}
+	return g.Server.Serve(listener)
+}
+
+// Shutdown stops the server from accepting new connections and waits up to
+// ShutdownTimeout for the in-flight requests to complete. Any connections
+// still open after the timeout are closed.
+func (g *GoHttpServer) Shutdown() {
+	g.shutdownOnce.Do(func() {
+		defer close(g.shutdownComplete)
+		ctx, cancel := context.WithTimeout(context.Background(), g.ShutdownTimeout)
+		defer cancel()
+
+		serverLogger.Info("Shutdown: Draining in-flight requests", "timeout", g.ShutdownTimeout)
+		if err := g.Server.Shutdown(ctx); err != nil {
+			serverLogger.Warn("Shutdown: Drain timed out, closing open connections", "error", err)
+			if err = g.Server.Close(); err != nil {
+				serverLogger.Error("Shutdown: Failed to close server", "error", err)
+			}
+		}
+
+		// Hijacked connections (websockets) are not tracked by the http.Server
+		drained := make(chan struct{})
+		go func() {
+			g.inFlight.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			serverLogger.Warn("Shutdown: Drain timed out with requests still active")
+		}
+	})
 }
 
 // Handle the request and response for the server.
 func (g *GoHttpServer) Handle(w http.ResponseWriter, r *http.Request) {
+	g.inFlight.Add(1)
+	defer g.inFlight.Done()
+	if r.ProtoMajor == 2 {
+		atomic.AddInt64(&g.http2Stats.totalStreams, 1)
+		atomic.AddInt64(&g.http2Stats.activeStreams, 1)
+		defer atomic.AddInt64(&g.http2Stats.activeStreams, -1)
+	}
+
 	// This section is called if the developer has added custom mux to the app
 	if g.HasAppMux && g.handleAppMux(w, r) {
 		return
@@ -145,21 +285,68 @@ func (g *GoHttpServer) handleMux(w http.ResponseWriter, r *http.Request) {
 	context.Response.SetResponse(w)
 
 	if upgrade == "websocket" || upgrade == "Websocket" {
-		websocket.Handler(func(ws *websocket.Conn) {
-			// Override default Read/Write timeout with sane value for a web socket request
-			if err := ws.SetDeadline(time.Now().Add(time.Hour * 24)); err != nil {
-				serverLogger.Error("SetDeadLine failed:", err)
-			}
+		serveGoWebSocket(w, r, func(ws *websocket.Conn, reader *goWebSocketReader) {
 			r.Method = "WS"
 			context.Request.WebSocket = ws
-			context.WebSocket = &GoWebSocket{Conn: ws, GoResponse: *context.Response}
+			context.WebSocket = &GoWebSocket{Conn: ws, GoResponse: *context.Response, reader: reader}
 			g.ServerInit.Callback(context)
-		}).ServeHTTP(w, r)
+		})
 	} else {
 		g.ServerInit.Callback(context)
 	}
 }
 
+// Upgrades the request to a websocket, the reads of the connection go through
+// the goWebSocketReader so a read timeout may be set once the handler runs.
+func serveGoWebSocket(w http.ResponseWriter, r *http.Request, handler func(ws *websocket.Conn, reader *goWebSocketReader)) {
+	reader := &goWebSocketReader{}
+	websocket.Handler(func(ws *websocket.Conn) {
+		// Override default Read/Write timeout with sane value for a web socket request
+		if err := ws.SetDeadline(time.Now().Add(time.Hour * 24)); err != nil {
+			serverLogger.Error("SetDeadLine failed:", err)
+		}
+		handler(ws, reader)
+	}).ServeHTTP(&goWebSocketHijacker{ResponseWriter: w, reader: reader}, r)
+}
+
+// Extends the read deadline of a websocket connection each time data is
+// received, the pong frames included.
+type goWebSocketReader struct {
+	reader  io.Reader
+	conn    net.Conn
+	timeout int64 // The read timeout in nanoseconds, zero for none
+}
+
+// Read reads from the connection, extending its read deadline.
+func (r *goWebSocketReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	if timeout := atomic.LoadInt64(&r.timeout); n > 0 && timeout > 0 {
+		_ = r.conn.SetReadDeadline(time.Now().Add(time.Duration(timeout)))
+	}
+	return n, err
+}
+
+// The response writer of a websocket request, the connection it hijacks
+// is read through the goWebSocketReader.
+type goWebSocketHijacker struct {
+	http.ResponseWriter
+	reader *goWebSocketReader
+}
+
+// Hijack hijacks the connection, wrapping its reader.
+func (h *goWebSocketHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	h.reader.reader, h.reader.conn = buf.Reader, conn
+	return conn, bufio.NewReadWriter(bufio.NewReader(h.reader), buf.Writer), nil
+}
+
 // ClientIP method returns client IP address from HTTP request.
 //
 // Note: Set property "app.behind.proxy" to true only if Revel is running
@@ -203,8 +390,10 @@ func (g *GoHttpServer) Name() string {
 // Returns stats for this engine.
 func (g *GoHttpServer) Stats() map[string]interface{} {
 	return map[string]interface{}{
-		"Go Engine Context": g.goContextStack.String(),
-		"Go Engine Forms":   g.goMultipartFormStack.String(),
+		"Go Engine Context":               g.goContextStack.String(),
+		"Go Engine Forms":                 g.goMultipartFormStack.String(),
+		"Go Engine HTTP/2 Active Streams": atomic.LoadInt64(&g.http2Stats.activeStreams),
+		"Go Engine HTTP/2 Total Streams":  atomic.LoadInt64(&g.http2Stats.totalStreams),
 	}
 }
 
@@ -224,9 +413,7 @@ func (g *GoHttpServer) Event(event Event, args interface{}) (r EventResponse) {
 			RaiseEvent(ENGINE_SHUTDOWN_REQUEST, nil)
 		}()
 	case ENGINE_SHUTDOWN_REQUEST:
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(Config.IntDefault("app.cancel.timeout", 60)))
-		defer cancel()
-		g.Server.Shutdown(ctx)
+		g.Shutdown()
 	default:
 	}
 
@@ -274,19 +461,26 @@ type (
 
 	// The websocket.
 	GoWebSocket struct {
-		Conn       *websocket.Conn // The connection
-		GoResponse                 // The response
+		Conn       *websocket.Conn    // The connection
+		GoResponse                    // The response
+		reader     *goWebSocketReader // The reader of the connection, nil if not served by the GoHttpServer
 	}
 
 	// The cookie.
 	GoCookie http.Cookie
 )
 
+// Returns a server with its counters initialized, it is configured by Init.
+func newGoHttpServer() *GoHttpServer {
+	return &GoHttpServer{http2Stats: &goHttp2Stats{}}
+}
+
 // Create a new go context.
 func NewGoContext(instance *GoHttpServer) *GoContext {
 	// This bit in here is for the test cases, which pass in a nil value
 	if instance == nil {
-		instance = &GoHttpServer{MaxMultipartSize: 32 << 20}
+		instance = newGoHttpServer()
+		instance.MaxMultipartSize = 32 << 20
 		instance.goContextStack = utils.NewStackLock(100, 200,
 			func() interface{} {
 				return NewGoContext(instance)
@@ -357,8 +551,15 @@ func (r *GoRequest) Get(key int) (value interface{}, err error) {
 }
 
 // Sets the request key with value.
-func (r *GoRequest) Set(key int, value interface{}) bool {
-	return false
+func (r *GoRequest) Set(key int, value interface{}) (result bool) {
+	switch key {
+	case HTTP_REQUEST_CONTEXT:
+		if ctx, ok := value.(context.Context); ok {
+			r.Original = r.Original.WithContext(ctx)
+			result = true
+		}
+	}
+	return
 }
 
 // Returns the form.
@@ -457,44 +658,18 @@ func (r *GoResponse) SetWriter(writer io.Writer) {
 
 // Write output to stream.
 func (r *GoResponse) WriteStream(name string, contentlen int64, modtime time.Time, reader io.Reader) error {
-	// Check to see if the output stream is modified, if not send it using the
-	// Native writer
-	written := false
-	if _, ok := r.Writer.(http.ResponseWriter); ok {
-		if rs, ok := reader.(io.ReadSeeker); ok {
-			http.ServeContent(r.Original, r.Request.Original, name, modtime, rs)
-			written = true
+	// Conditional and range requests are answered by the BinaryResult, which
+	// works for any writer, so this only needs to copy the content.
+	if contentlen != -1 {
+		header := ServerHeader(r.Goheader)
+		if writer, found := r.Writer.(*CompressResponseWriter); found {
+			header = ServerHeader(writer.Header)
 		}
+		header.Set("Content-Length", strconv.FormatInt(contentlen, 10))
 	}
-	if !written {
-		// Else, do a simple io.Copy.
-		ius := r.Request.Original.Header.Get("If-Unmodified-Since")
-		if t, err := http.ParseTime(ius); err == nil && !modtime.IsZero() {
-			// The Date-Modified header truncates sub-second precision, so
-			// use mtime < t+1s instead of mtime <= t to check for unmodified.
-			if modtime.Before(t.Add(1 * time.Second)) {
-				h := r.Original.Header()
-				delete(h, "Content-Type")
-				delete(h, "Content-Length")
-				if h.Get("Etag") != "" {
-					delete(h, "Last-Modified")
-				}
-				r.Original.WriteHeader(http.StatusNotModified)
-				return nil
-			}
-		}
-
-		if contentlen != -1 {
-			header := ServerHeader(r.Goheader)
-			if writer, found := r.Writer.(*CompressResponseWriter); found {
-				header = ServerHeader(writer.Header)
-			}
-			header.Set("Content-Length", strconv.FormatInt(contentlen, 10))
-		}
-		if _, err := io.Copy(r.Writer, reader); err != nil {
-			r.Original.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+	if _, err := io.Copy(r.Writer, reader); err != nil {
+		r.Original.WriteHeader(http.StatusInternalServerError)
+		return err
 	}
 	return nil
 }
@@ -639,3 +814,39 @@ func (g *GoWebSocket) MessageSend(v interface{}) error {
 func (g *GoWebSocket) MessageReceive(v interface{}) error {
 	return websocket.Message.Receive(g.Conn, v)
 }
+
+/**
+ * Ping sends a ping frame, which the client answers with a pong.
+ */
+func (g *GoWebSocket) Ping() error {
+	payloadType := g.Conn.PayloadType
+	g.Conn.PayloadType = websocket.PingFrame
+	_, err := g.Conn.Write(nil)
+	g.Conn.PayloadType = payloadType
+	return err
+}
+
+/**
+ * Sets the deadline for the reads from the connection to the timeout from
+ * now, it is extended each time data is received (pongs included).
+ */
+func (g *GoWebSocket) SetReadTimeout(timeout time.Duration) error {
+	if g.reader != nil {
+		atomic.StoreInt64(&g.reader.timeout, int64(timeout))
+	}
+	return g.Conn.SetReadDeadline(time.Now().Add(timeout))
+}
+
+/**
+ * Sets the deadline for the writes to the connection.
+ */
+func (g *GoWebSocket) SetWriteDeadline(t time.Time) error {
+	return g.Conn.SetWriteDeadline(t)
+}
+
+/**
+ * Close the connection.
+ */
+func (g *GoWebSocket) Close() error {
+	return g.Conn.Close()
+}
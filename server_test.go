@@ -5,15 +5,20 @@
 package revel
 
 import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 )
 
 // This tries to benchmark the usual request-serving pipeline to get an overall
@@ -140,6 +145,174 @@ func TestOnAppStop(t *testing.T) {
 	a.Equal("goodbye cruel world", i, "Did not get shutdown events")
 }
 
+// Ensure a shutdown request waits for the in-flight requests to complete.
+func TestGoHttpServerShutdownDrains(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	address := listener.Addr().String()
+	a.Nil(listener.Close())
+
+	started, finished := make(chan bool, 1), make(chan bool, 1)
+	server := &GoHttpServer{}
+	server.Init(&EngineInit{Address: address, Network: "tcp", Callback: func(ctx ServerContext) {
+		started <- true
+		time.Sleep(300 * time.Millisecond)
+		ctx.GetResponse().(*GoResponse).Original.WriteHeader(http.StatusAccepted)
+		finished <- true
+	}})
+	stopped := make(chan bool)
+	go func() {
+		server.Start()
+		stopped <- true
+	}()
+
+	status := make(chan int, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if resp, err := http.Get("http://" + address + "/"); err == nil {
+				status <- resp.StatusCode
+				_ = resp.Body.Close()
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		status <- 0
+	}()
+
+	<-started
+	server.Event(ENGINE_SHUTDOWN_REQUEST, nil)
+	a.Len(finished, 1, "Shutdown returned before the in-flight request completed")
+	<-stopped
+	a.Equal(http.StatusAccepted, <-status)
+}
+
+func TestGoHttpServerH2C(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	server := &GoHttpServer{}
+	server.Init(&EngineInit{Network: "tcp", HTTP2: EngineHTTP2{Cleartext: true}, Callback: func(ctx ServerContext) {
+		stats := server.Stats()
+		a.Equal(int64(1), stats["Go Engine HTTP/2 Active Streams"])
+		_, _ = ctx.GetResponse().(*GoResponse).Original.Write([]byte(ctx.GetRequest().(*GoRequest).Original.Proto))
+	}})
+	ts := httptest.NewServer(server.Server.Handler)
+	defer ts.Close()
+
+	// A prior knowledge HTTP/2 client over a plain TCP connection
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get(ts.URL)
+	a.Nil(err)
+	defer func() { _ = resp.Body.Close() }()
+	a.Equal(2, resp.ProtoMajor)
+
+	stats := server.Stats()
+	a.Equal(int64(0), stats["Go Engine HTTP/2 Active Streams"])
+	a.Equal(int64(1), stats["Go Engine HTTP/2 Total Streams"])
+}
+
+func TestNewGoContextStats(t *testing.T) {
+	a := assert.New(t)
+	// The server of the test contexts has its counters too
+	stats := NewGoContext(nil).Request.Engine.Stats()
+	a.Equal(int64(0), stats["Go Engine HTTP/2 Total Streams"])
+}
+
+func TestGoHttpServerListeners(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	dir, err := ioutil.TempDir("", "revel-listeners")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "health.socket")
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	server := &GoHttpServer{}
+	server.Init(&EngineInit{
+		Network: "tcp",
+		Address: tcp.Addr().String(),
+		Listeners: []*EngineListener{
+			{Network: "tcp", Address: tcp.Addr().String(), Listener: tcp},
+			{Network: "unix", Address: socket},
+		},
+		Callback: func(ctx ServerContext) {
+			ctx.GetResponse().(*GoResponse).Original.WriteHeader(http.StatusNoContent)
+		},
+	})
+	stopped := make(chan bool)
+	go func() {
+		server.Start()
+		stopped <- true
+	}()
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	for _, test := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{http.DefaultClient, "http://" + tcp.Addr().String() + "/"},
+		{unixClient, "http://unix/"},
+	} {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = test.client.Get(test.url); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if a.Nil(err, test.url) {
+			a.Equal(http.StatusNoContent, resp.StatusCode, test.url)
+			_ = resp.Body.Close()
+		}
+	}
+
+	server.Event(ENGINE_SHUTDOWN_REQUEST, nil)
+	<-stopped
+}
+
+func TestActivatedListeners(t *testing.T) {
+	a := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	a.Nil(err)
+	defer file.Close()
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "web")
+	activated, err := activatedListeners(int(file.Fd()))
+	a.Nil(err)
+	a.Len(activated, 1)
+	a.Equal("web", activated[0].Name)
+	a.Equal(listener.Addr().String(), activated[0].Address)
+	a.Nil(activated[0].Listener.Close())
+	a.Equal("", os.Getenv("LISTEN_FDS"), "The environment should be cleared")
+
+	// Sockets meant for another process are ignored
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	activated, err = activatedListeners(int(file.Fd()))
+	a.Nil(err)
+	a.Len(activated, 0)
+}
+
 var (
 	showRequest, _      = http.NewRequest("GET", "/hotels/3", nil)
 	staticRequest, _    = http.NewRequest("GET", "/public/js/sessvars.js", nil)
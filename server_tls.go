@@ -0,0 +1,208 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TLSCertificatePair is a certificate and key file pair to be served.
+type TLSCertificatePair struct {
+	Name     string // The name of the pair in the configuration, empty for the default pair
+	CertFile string // e.g. "/path/to/cert.pem"
+	KeyFile  string // e.g. "/path/to/key.pem"
+}
+
+// TLSCertificateLoader serves the certificates for the tls.Config, the
+// certificate is selected by the server name (SNI) sent by the client. The
+// loader is a DiscerningListener so the pairs are reloaded whenever the files
+// change on disk, this allows certificates to be rotated without a restart.
+// The files may be symlinks swapped to rotate the certificates, as those of
+// the Kubernetes secret volumes which point through a ..data directory link.
+type TLSCertificateLoader struct {
+	Pairs        []*TLSCertificatePair       // The configured pairs, the first is the default
+	certificates map[string]*tls.Certificate // The certificates by lower case host name, may contain wildcards
+	defaultCert  *tls.Certificate            // The certificate used when no host name matches
+	watched      map[string]bool             // The base names of the watched files
+	resolved     []string                    // The paths the loaded files resolved to, in the order of the pairs
+	mutex        sync.RWMutex                // Guards the certificates on reload
+}
+
+// NewTLSCertificateLoader returns a loader for the pairs configured in the
+// app.conf. The default pair is read from http.sslcert and http.sslkey,
+// additional pairs are read from http.sslcert.<name> and http.sslkey.<name>.
+func NewTLSCertificateLoader() (*TLSCertificateLoader, error) {
+	pairs := []*TLSCertificatePair{{CertFile: HTTPSslCert, KeyFile: HTTPSslKey}}
+	for _, option := range Config.Options("http.sslcert.") {
+		name := strings.TrimPrefix(option, "http.sslcert.")
+		key, found := Config.String("http.sslkey." + name)
+		if !found {
+			return nil, errors.New("No http.sslkey." + name + " found for " + option)
+		}
+		cert, _ := Config.String(option)
+		pairs = append(pairs, &TLSCertificatePair{Name: name, CertFile: cert, KeyFile: key})
+	}
+	return NewTLSCertificateLoaderFromPairs(pairs...)
+}
+
+// NewTLSCertificateLoaderFromPairs returns a loader for the pairs, the first
+// pair is used as the default certificate.
+func NewTLSCertificateLoaderFromPairs(pairs ...*TLSCertificatePair) (*TLSCertificateLoader, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("No certificate pairs provided")
+	}
+	loader := &TLSCertificateLoader{Pairs: pairs, watched: map[string]bool{}}
+	for _, pair := range pairs {
+		loader.watched[filepath.Base(pair.CertFile)] = true
+		loader.watched[filepath.Base(pair.KeyFile)] = true
+	}
+	if err := loader.load(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// Watch starts watching the directories of the certificate files, the pairs
+// are reloaded as soon as a change is detected.
+func (l *TLSCertificateLoader) Watch() {
+	dirs := map[string]bool{}
+	for _, pair := range l.Pairs {
+		dirs[filepath.Dir(pair.CertFile)] = true
+		dirs[filepath.Dir(pair.KeyFile)] = true
+	}
+	roots := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		roots = append(roots, dir)
+	}
+
+	watcher := NewWatcher()
+	watcher.serial = true
+	watcher.eager = true
+	watcher.dotfiles = true
+	watcher.Listen(l, roots...)
+}
+
+// Refresh reloads the certificate pairs, if any pair fails to load the
+// previously loaded certificates continue to be served.
+func (l *TLSCertificateLoader) Refresh() *Error {
+	if err := l.load(); err != nil {
+		serverLogger.Error("Refresh: Failed to reload certificates, keeping the current ones", "error", err)
+		return &Error{
+			Title:       "TLS Certificate Error",
+			Description: err.Error(),
+		}
+	}
+	serverLogger.Info("Refresh: Reloaded certificates", "pairs", len(l.Pairs))
+	return nil
+}
+
+// WatchDir only watches the directories containing the certificate files,
+// subdirectories are skipped.
+func (l *TLSCertificateLoader) WatchDir(info os.FileInfo) bool {
+	for _, pair := range l.Pairs {
+		if info.Name() == filepath.Base(filepath.Dir(pair.CertFile)) ||
+			info.Name() == filepath.Base(filepath.Dir(pair.KeyFile)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchFile returns true for the certificate and key files, and for any other
+// file once the certificate files resolve to other paths than those loaded. A
+// swapped symlink directory, e.g. ..data, changes no file of the pairs itself.
+func (l *TLSCertificateLoader) WatchFile(basename string) bool {
+	if l.watched[filepath.Base(basename)] {
+		return true
+	}
+	l.mutex.RLock()
+	loaded := l.resolved
+	l.mutex.RUnlock()
+	for i, path := range l.resolvePaths() {
+		if i >= len(loaded) || loaded[i] != path {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCertificate returns the certificate for the server name in the client
+// hello, it is assigned to the tls.Config. An exact host name match is
+// preferred over a wildcard match, when neither matches the default
+// certificate is returned.
+func (l *TLSCertificateLoader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if cert, found := l.certificates[name]; found {
+		return cert, nil
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if cert, found := l.certificates["*"+name[i:]]; found {
+			return cert, nil
+		}
+	}
+	return l.defaultCert, nil
+}
+
+// Loads all the pairs and indexes the certificates by the names they are valid for.
+func (l *TLSCertificateLoader) load() error {
+	resolved := l.resolvePaths()
+	certificates := map[string]*tls.Certificate{}
+	var defaultCert *tls.Certificate
+	for i, pair := range l.Pairs {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return err
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return err
+			}
+		}
+		if i == 0 {
+			defaultCert = &cert
+		}
+		names := cert.Leaf.DNSNames
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = []string{cert.Leaf.Subject.CommonName}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			// The first pair configured for a name wins
+			if _, found := certificates[name]; !found {
+				certificates[name] = &cert
+			}
+		}
+	}
+
+	l.mutex.Lock()
+	l.certificates = certificates
+	l.defaultCert = defaultCert
+	l.resolved = resolved
+	l.mutex.Unlock()
+	return nil
+}
+
+// Returns the paths the certificate and key files of the pairs resolve to.
+func (l *TLSCertificateLoader) resolvePaths() []string {
+	paths := make([]string, 0, 2*len(l.Pairs))
+	for _, pair := range l.Pairs {
+		for _, file := range []string{pair.CertFile, pair.KeyFile} {
+			if path, err := filepath.EvalSymlinks(file); err == nil {
+				file = path
+			}
+			paths = append(paths, file)
+		}
+	}
+	return paths
+}
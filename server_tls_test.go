@@ -0,0 +1,153 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes a self signed certificate valid for the names to dir/name.crt and dir/name.key.
+func writeTestCertificate(t *testing.T, dir, name string, serial int64, names ...string) *TLSCertificatePair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair := &TLSCertificatePair{
+		Name:     name,
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	if err = ioutil.WriteFile(pair.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(pair.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func serialFor(t *testing.T, loader *TLSCertificateLoader, serverName string) int64 {
+	cert, err := loader.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestTLSCertificateLoaderSNI(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "revel-tls")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+
+	loader, err := NewTLSCertificateLoaderFromPairs(
+		writeTestCertificate(t, dir, "default", 1, "example.com"),
+		writeTestCertificate(t, dir, "api", 2, "api.example.com"),
+		writeTestCertificate(t, dir, "wildcard", 3, "*.example.com"),
+	)
+	a.Nil(err)
+
+	a.Equal(int64(1), serialFor(t, loader, "example.com"))
+	a.Equal(int64(2), serialFor(t, loader, "API.example.com"), "Exact matches are case insensitive")
+	a.Equal(int64(3), serialFor(t, loader, "www.example.com"), "Wildcard match expected")
+	a.Equal(int64(1), serialFor(t, loader, "a.b.example.com"), "Wildcards only match a single label")
+	a.Equal(int64(1), serialFor(t, loader, ""), "Default certificate expected without SNI")
+
+	a.True(loader.WatchFile(filepath.Join(dir, "api.key")))
+	a.False(loader.WatchFile(filepath.Join(dir, "other.key")))
+}
+
+func TestTLSCertificateLoaderRefresh(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "revel-tls")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+
+	loader, err := NewTLSCertificateLoaderFromPairs(writeTestCertificate(t, dir, "default", 1, "example.com"))
+	a.Nil(err)
+	a.Equal(int64(1), serialFor(t, loader, "example.com"))
+
+	// A rotated certificate is served after the refresh
+	writeTestCertificate(t, dir, "default", 2, "example.com")
+	a.Nil(loader.Refresh())
+	a.Equal(int64(2), serialFor(t, loader, "example.com"))
+
+	// A broken certificate leaves the current one in place
+	a.Nil(ioutil.WriteFile(filepath.Join(dir, "default.crt"), []byte("invalid"), 0600))
+	a.NotNil(loader.Refresh())
+	a.Equal(int64(2), serialFor(t, loader, "example.com"))
+}
+
+// Writes the pair to a timestamped directory and points the ..data link to it,
+// as the kubelet does for a secret volume, see TestTLSCertificateLoaderSymlinkSwap.
+func swapTestCertificate(t *testing.T, dir, version string, serial int64) {
+	writeTestCertificate(t, filepath.Join(dir, version), "default", serial, "example.com")
+	if err := os.Symlink(version, filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSCertificateLoaderSymlinkSwap(t *testing.T) {
+	startFakeBookingApp()
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "revel-tls")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+
+	a.Nil(os.Mkdir(filepath.Join(dir, "..v1"), 0700))
+	swapTestCertificate(t, dir, "..v1", 1)
+	pair := &TLSCertificatePair{CertFile: filepath.Join(dir, "default.crt"), KeyFile: filepath.Join(dir, "default.key")}
+	a.Nil(os.Symlink(filepath.Join("..data", "default.crt"), pair.CertFile))
+	a.Nil(os.Symlink(filepath.Join("..data", "default.key"), pair.KeyFile))
+
+	loader, err := NewTLSCertificateLoaderFromPairs(pair)
+	a.Nil(err)
+	a.Equal(int64(1), serialFor(t, loader, "example.com"))
+	a.False(loader.WatchFile(filepath.Join(dir, "..data")))
+	loader.Watch()
+
+	// The swap of the ..data link only changes the dotfiles of the directory
+	a.Nil(os.Mkdir(filepath.Join(dir, "..v2"), 0700))
+	swapTestCertificate(t, dir, "..v2", 2)
+	a.Nil(os.RemoveAll(filepath.Join(dir, "..v1")))
+	for i := 0; i < 100 && serialFor(t, loader, "example.com") != 2; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	a.Equal(int64(2), serialFor(t, loader, "example.com"))
+	a.False(loader.WatchFile(filepath.Join(dir, "..data")))
+}
@@ -74,3 +74,36 @@ func TestCookieSessionExpire(t *testing.T) {
 		t.Error("expect expires", cookie.Expires, "before", expectExpire)
 	}
 }
+
+func TestCookieEncrypted(t *testing.T) {
+	a := assert.New(t)
+	session.InitSession(revel.RevelLog)
+	revel.CookieEncrypt = true
+	defer func() {
+		revel.CookieEncrypt = false
+		_ = revel.SetSecretKey(nil)
+		revel.SetPreviousSecretKeys()
+	}()
+	_ = revel.SetSecretKey([]byte("old"))
+
+	cse := revel.NewSessionCookieEngine()
+	originSession := session.NewSession()
+	originSession["user"] = "Tom"
+	cookie := cse.GetCookie(originSession)
+	a.NotContains(cookie.Value, "Tom")
+
+	// Cookies encrypted with the previous key are accepted
+	_ = revel.SetSecretKey([]byte("new"))
+	revel.SetPreviousSecretKeys([]byte("old"))
+	restoredSession := session.NewSession()
+	cse.DecodeCookie(revel.GoCookie(*cookie), restoredSession)
+	a.Equal("Tom", restoredSession["user"])
+
+	// Signed cookies are not accepted once the cookies are encrypted
+	revel.CookieEncrypt = false
+	signed := cse.GetCookie(originSession)
+	revel.CookieEncrypt = true
+	restoredSession = session.NewSession()
+	cse.DecodeCookie(revel.GoCookie(*signed), restoredSession)
+	a.Nil(restoredSession["user"])
+}
@@ -69,17 +69,8 @@ func (cse *SessionCookieEngine) Encode(c *Controller) {
 // Exposed only for testing purposes.
 func (cse *SessionCookieEngine) DecodeCookie(cookie ServerCookie, s session.Session) {
 	// Decode the session from a cookie.
-	// Separate the data from the signature.
-	cookieValue := cookie.GetValue()
-	hyphen := strings.Index(cookieValue, "-")
-	if hyphen == -1 || hyphen >= len(cookieValue)-1 {
-		return
-	}
-	sig, data := cookieValue[:hyphen], cookieValue[hyphen+1:]
-
-	// Verify the signature.
-	if !Verify(data, sig) {
-		sessionEngineLog.Warn("Session cookie signature failed")
+	data, ok := cse.openCookieValue(cookie.GetValue())
+	if !ok {
 		return
 	}
 
@@ -130,7 +121,7 @@ func (cse *SessionCookieEngine) GetCookie(s session.Session) *http.Cookie {
 	sessionData := url.QueryEscape(sessionValue)
 	sessionCookie := &http.Cookie{
 		Name:     CookiePrefix + session.SessionCookieSuffix,
-		Value:    Sign(sessionData) + "-" + sessionData,
+		Value:    sealCookieValue(CookiePrefix+session.SessionCookieSuffix, sessionData),
 		Domain:   CookieDomain,
 		Path:     "/",
 		HttpOnly: true,
@@ -141,3 +132,43 @@ func (cse *SessionCookieEngine) GetCookie(s session.Session) *http.Cookie {
 	}
 	return sessionCookie
 }
+
+// Verifies the signed session cookie value, or decrypts it when the cookies
+// are encrypted, returning the session data.
+func (cse *SessionCookieEngine) openCookieValue(cookieValue string) (data string, ok bool) {
+	if CookieEncrypt {
+		data, err := Decrypt(cookieValue, CookiePrefix+session.SessionCookieSuffix)
+		if err != nil {
+			sessionEngineLog.Warn("Session cookie decryption failed", "error", err)
+			return "", false
+		}
+		return data, true
+	}
+
+	// Separate the data from the signature.
+	hyphen := strings.Index(cookieValue, "-")
+	if hyphen == -1 || hyphen >= len(cookieValue)-1 {
+		return "", false
+	}
+	sig, data := cookieValue[:hyphen], cookieValue[hyphen+1:]
+
+	// Verify the signature.
+	if !Verify(data, sig) {
+		sessionEngineLog.Warn("Session cookie signature failed")
+		return "", false
+	}
+	return data, true
+}
+
+// Returns the value of the named cookie holding the data, encrypted if
+// CookieEncrypt is set and signed otherwise.
+func sealCookieValue(name, data string) string {
+	if !CookieEncrypt {
+		return Sign(data) + "-" + data
+	}
+	value, err := Encrypt(data, name)
+	if err != nil {
+		sessionEngineLog.Error("Cookie encryption failed", "cookie", name, "error", err)
+	}
+	return value
+}
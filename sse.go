@@ -0,0 +1,171 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	sseLog = RevelLog.New("section", "sse")
+
+	// The interval between heartbeats on an idle event stream, set via
+	// results.sse.heartbeat (e.g. "15s"), 0 for no heartbeats
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+func init() {
+	OnAppStart(func() {
+		eventStreamHeartbeat = ConfigDuration("results.sse.heartbeat", 15*time.Second)
+	})
+}
+
+// ServerSentEvent is a single event sent on an event stream.
+type ServerSentEvent struct {
+	ID    string        // The event id, the client sends the last one it received in Last-Event-ID when it reconnects
+	Event string        // The event type, empty for the default "message" type
+	Data  interface{}   // The data, a string or []byte is sent as is, anything else is encoded as JSON
+	Retry time.Duration // The time the client waits before reconnecting, 0 to leave it unchanged
+}
+
+// EventStreamResult sends the events from a channel to the client as a
+// text/event-stream (server sent events). The stream ends when the channel is
+// closed or the client disconnects.
+type EventStreamResult struct {
+	Events    <-chan ServerSentEvent
+	Heartbeat time.Duration // The interval between heartbeats on an idle stream, 0 for no heartbeats
+}
+
+// RenderEventStream returns a result which streams the events to the client
+// until the channel is closed or the client disconnects. The producer should
+// stop sending when Controller.StreamContext is done, which unlike
+// Controller.Context does not end at the deadline of the TimeoutFilter. The
+// producer outlives the action, so it must not use the controller, for
+// example:
+//
+//	func (c Dashboard) Updates() revel.Result {
+//		ctx, lastEventID := c.StreamContext(), c.LastEventID()
+//		events := make(chan revel.ServerSentEvent)
+//		go func() {
+//			defer close(events)
+//			for update := range metrics.Since(lastEventID) {
+//				select {
+//				case events <- revel.ServerSentEvent{ID: update.ID, Data: update}:
+//				case <-ctx.Done():
+//					return
+//				}
+//			}
+//		}()
+//		return c.RenderEventStream(events)
+//	}
+func (c *Controller) RenderEventStream(events <-chan ServerSentEvent) Result {
+	c.setStatusIfNil(http.StatusOK)
+
+	return &EventStreamResult{
+		Events:    events,
+		Heartbeat: eventStreamHeartbeat,
+	}
+}
+
+// LastEventID returns the id of the last event received by a client which is
+// reconnecting to an event stream, or an empty string.
+func (c *Controller) LastEventID() string {
+	return c.Request.GetHttpHeader("Last-Event-ID")
+}
+
+// Apply writes the events to the response as they are received.
+func (r *EventStreamResult) Apply(req *Request, resp *Response) {
+	resp.Out.internalHeader.Set("Cache-Control", "no-cache")
+	// Stop proxies (nginx) from buffering the stream
+	resp.Out.internalHeader.Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK, "text/event-stream; charset=utf-8")
+
+	writer := resp.GetWriter()
+	flush := func() {
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	// Send the headers to the client before the first event
+	flush()
+
+	var done <-chan struct{}
+	if ctx := req.Context(); ctx != nil {
+		done = ctx.Done()
+	}
+	var heartbeat <-chan time.Time
+	if r.Heartbeat > 0 {
+		ticker := time.NewTicker(r.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-done:
+			sseLog.Debug("Apply: Client disconnected from event stream", "path", req.GetPath())
+			return
+		case event, ok := <-r.Events:
+			if !ok {
+				return
+			}
+			err = event.write(writer)
+		case <-heartbeat:
+			// A comment line, ignored by the client but keeps the connection open
+			_, err = io.WriteString(writer, ": heartbeat\n\n")
+		}
+		if err != nil {
+			sseLog.Debug("Apply: Error writing to event stream", "path", req.GetPath(), "error", err)
+			return
+		}
+		flush()
+	}
+}
+
+// Writes the event in the text/event-stream format.
+func (e ServerSentEvent) write(writer io.Writer) (err error) {
+	var data []byte
+	switch value := e.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		if data, err = json.Marshal(value); err != nil {
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if e.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", stripNewlines(e.ID))
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", stripNewlines(e.Event))
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry/time.Millisecond)
+	}
+	// Each line of the data is sent as a separate data field
+	for _, line := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	_, err = writer.Write(buf.Bytes())
+	return
+}
+
+// Removes line breaks, which would end the field.
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -162,6 +162,28 @@ var TemplateFuncs = map[string]interface{}{
 		return ""
 	},
 
+	// The CSRF token of the visitor, see CSRFFilter.
+	"csrfToken": func(viewArgs map[string]interface{}) string {
+		token, _ := viewArgs[csrfViewArg].(string)
+		return token
+	},
+	// A hidden form field holding the CSRF token.
+	"csrfField": func(viewArgs map[string]interface{}) template.HTML {
+		token, _ := viewArgs[csrfViewArg].(string)
+		return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+			html.EscapeString(csrfField), html.EscapeString(token)))
+	},
+	// Sign a value with the secret key, see Sign.
+	"sign": Sign,
+	// Make a signed token which expires after the duration (e.g. "1h"), see SignToken.
+	"signToken": func(value string, ttl string) (string, error) {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return "", err
+		}
+		return SignToken(value, duration)
+	},
+
 	"slug": Slug,
 	"even": func(a int) bool { return (a % 2) == 0 },
 
@@ -222,6 +244,13 @@ func ReverseURL(args ...interface{}) (template.URL, error) {
 		return template.URL(AppRoot), nil
 	}
 
+	// The arguments of a named route fill its path parameters in order
+	if MainRouter != nil {
+		if route := MainRouter.RouteByName(action); route != nil {
+			return reverseNamedURL(route, action, args[1:])
+		}
+	}
+
 	pathData, found := splitActionPath(nil, action, true)
 
 	if !found {
@@ -256,6 +285,25 @@ func ReverseURL(args ...interface{}) (template.URL, error) {
 	return template.URL(MainRouter.Reverse(args[0].(string), argsByName).URL), nil
 }
 
+// Returns the url of the named route, the arguments filling the parameters of
+// its host and path in order.
+func reverseNamedURL(route *Route, name string, args []interface{}) (template.URL, error) {
+	params := append(append([]string{}, route.hostParams...), route.wildcards...)
+	if len(params) != len(args) {
+		return "", fmt.Errorf("reversing %s: route defines %d args, but received %d",
+			name, len(params), len(args))
+	}
+	argsByName := make(map[string]string)
+	for i, argValue := range args {
+		Unbind(argsByName, params[i], argValue)
+	}
+	ad, err := MainRouter.ReverseError(name, argsByName, nil)
+	if err != nil {
+		return "", err
+	}
+	return template.URL(ad.URL), nil
+}
+
 func Slug(text string) string {
 	separator := "-"
 	text = strings.ToLower(text)
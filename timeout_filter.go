@@ -0,0 +1,82 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// The filter option holding the time.Duration allowed for an action.
+const TimeoutFilterOption = "timeout"
+
+// The controller argument holding the context without the deadline, see
+// Controller.StreamContext.
+const timeoutParentArg = "timeout.parent"
+
+var (
+	timeoutLog = RevelLog.New("section", "timeout")
+
+	// The time allowed for an action, set via server.timeout.action (e.g. "30s"), 0 for no limit
+	actionTimeout time.Duration
+	// The status returned when an action overruns, set via server.timeout.status (503 or 504)
+	actionTimeoutStatus = http.StatusServiceUnavailable
+)
+
+func init() {
+	OnAppStart(func() {
+		actionTimeout = ConfigDuration("server.timeout.action", 0)
+		actionTimeoutStatus = Config.IntDefault("server.timeout.status", http.StatusServiceUnavailable)
+	})
+}
+
+// Timeout sets the time allowed for the controller or action, overriding
+// server.timeout.action. A zero duration removes the limit.
+//   revel.FilterAction(App.Report).
+//     Timeout(2 * time.Minute)
+func (conf FilterConfigurator) Timeout(timeout time.Duration) FilterConfigurator {
+	return conf.SetOption(TimeoutFilterOption, timeout)
+}
+
+// TimeoutFilter sets a deadline on the request context for the remainder of
+// the filter chain. The context is available from Controller.Context, an action
+// which passes it on to its database calls is interrupted when the deadline
+// passes. If the deadline has passed when the action returns its result is
+// replaced with a 503 (or server.timeout.status) error.
+//
+// The action is not preempted, the timeout is only detected once it returns.
+// An action which ignores the context runs to completion, and the client
+// waits for it before receiving the error.
+func TimeoutFilter(c *Controller, fc []Filter) {
+	timeout := actionTimeout
+	if value, found := c.FilterOption(TimeoutFilterOption); found {
+		timeout, _ = value.(time.Duration)
+	}
+	if timeout <= 0 {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	parent := c.Context()
+	c.Args[timeoutParentArg] = parent
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	// The result is applied after the filters return (or panic), so it gets
+	// the original context back rather than the canceled one
+	defer c.Request.SetContext(parent)
+	c.Request.SetContext(ctx)
+	fc[0](c, fc[1:])
+
+	// Only report the timeout if the deadline was ours, not a client disconnect
+	if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		timeoutLog.Warn("TimeoutFilter: Action exceeded its deadline", "action", c.Action, "timeout", timeout)
+		c.Response.Status = actionTimeoutStatus
+		c.Result = c.RenderError(&Error{
+			Title:       http.StatusText(actionTimeoutStatus),
+			Description: "The request did not complete within the time allowed",
+		})
+	}
+}
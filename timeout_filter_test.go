@@ -0,0 +1,62 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutFilter(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+	defer delete(filterOptions, "Hotels.Show")
+
+	FilterAction(Hotels.Show).Timeout(20 * time.Millisecond)
+	c := NewTestController(httptest.NewRecorder(), showRequest)
+	a.Nil(c.SetAction("Hotels", "Show"))
+
+	var actionErr error
+	TimeoutFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		// A downstream call honoring the context
+		<-c.Context().Done()
+		actionErr = c.Context().Err()
+		c.Result = c.RenderText("late")
+	}})
+	a.Equal(context.DeadlineExceeded, actionErr)
+	a.Equal(http.StatusServiceUnavailable, c.Response.Status)
+	a.IsType(ErrorResult{}, c.Result)
+	a.Nil(c.Context().Err(), "Expected the result to be applied with the original context")
+}
+
+func TestTimeoutFilterWithinDeadline(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+	defer delete(filterOptions, "Hotels.Show")
+
+	FilterAction(Hotels.Show).Timeout(time.Second)
+	c := NewTestController(httptest.NewRecorder(), showRequest)
+	a.Nil(c.SetAction("Hotels", "Show"))
+
+	var stream context.Context
+	TimeoutFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		_, hasDeadline := c.Context().Deadline()
+		a.True(hasDeadline)
+		stream = c.StreamContext()
+		c.Result = c.RenderText("on time")
+	}})
+	a.Equal(http.StatusOK, c.Response.Status)
+	a.IsType(&RenderTextResult{}, c.Result)
+
+	// The stream context outlives the action and its deadline
+	_, hasDeadline := stream.Deadline()
+	a.False(hasDeadline)
+	a.Nil(stream.Err())
+}
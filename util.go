@@ -16,6 +16,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/revel/config"
 )
@@ -134,6 +135,22 @@ func DirExists(filename string) bool {
 	return err == nil && fileInfo.IsDir()
 }
 
+// ConfigDuration returns the duration of the configuration key (e.g. "30s"),
+// or the default if it is not set or invalid.
+func ConfigDuration(key string, defaultValue time.Duration) time.Duration {
+	if Config == nil {
+		return defaultValue
+	}
+	if value, found := Config.String(key); found {
+		duration, err := time.ParseDuration(value)
+		if err == nil {
+			return duration
+		}
+		utilLog.Error("ConfigDuration: Invalid duration", "key", key, "value", value, "error", err)
+	}
+	return defaultValue
+}
+
 func FirstNonEmpty(strs ...string) string {
 	for _, str := range strs {
 		if len(str) > 0 {
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestContentTypeByFilename(t *testing.T) {
@@ -96,3 +97,16 @@ func TestEqual(t *testing.T) {
 	testRow("strings2", "strings", false)
 	testRow("strings", "strings2", false)
 }
+
+func TestConfigDuration(t *testing.T) {
+	startFakeBookingApp()
+	defer Config.SetOption("test.duration", "")
+	Config.SetOption("test.duration", "90s")
+	if duration := ConfigDuration("test.duration", time.Second); duration != 90*time.Second {
+		t.Errorf("Expected the configured duration, got %s", duration)
+	}
+	Config.SetOption("test.duration", "soon")
+	if duration := ConfigDuration("test.duration", time.Second); duration != time.Second {
+		t.Errorf("Expected the default for an invalid duration, got %s", duration)
+	}
+}
@@ -32,6 +32,8 @@ type DiscerningListener interface {
 // directory.
 type Watcher struct {
 	serial              bool                // true to process events in serial
+	eager               bool                // true to notify the listeners as soon as an event is received
+	dotfiles            bool                // true to let the listeners decide on the changes to dotfiles
 	watchers            []*fsnotify.Watcher // Parallel arrays of watcher/listener pairs.
 	listeners           []Listener          // List of listeners for watcher
 	forceRefresh        bool                // True to force the refresh
@@ -265,16 +267,16 @@ func (w *Watcher) notifyInProcess(listener Listener) (err *Error) {
 
 // If watch.mode is set to eager, the application is rebuilt immediately
 // when a source file is changed.
-// This feature is available only in dev mode.
+// This feature is available only in dev mode, unless the watcher was created eager.
 func (w *Watcher) eagerRebuildEnabled() bool {
-	return Config.BoolDefault("mode.dev", true) &&
+	return w.eager || Config.BoolDefault("mode.dev", true) &&
 		Config.BoolDefault("watch", true) &&
 		Config.StringDefault("watch.mode", "normal") == "eager"
 }
 
 func (w *Watcher) rebuildRequired(ev fsnotify.Event, listener Listener) bool {
 	// Ignore changes to dotfiles.
-	if strings.HasPrefix(filepath.Base(ev.Name), ".") {
+	if !w.dotfiles && strings.HasPrefix(filepath.Base(ev.Name), ".") {
 		return false
 	}
 
@@ -0,0 +1,483 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	websocketLog = RevelLog.New("section", "websocket")
+
+	// Returned when sending to a connection which has been closed.
+	ErrWebSocketClosed = errors.New("Websocket connection closed")
+	// Returned when sending to a connection whose send queue is full.
+	ErrWebSocketQueueFull = errors.New("Websocket send queue full")
+)
+
+// WebSocketMessage is a message exchanged between the WebSocketHub and the
+// clients, encoded as JSON. For example:
+//
+//	{"type": "chat", "room": "lobby", "data": {"text": "Hello"}}
+type WebSocketMessage struct {
+	Type string          `json:"type"`           // The type, which selects the handler
+	Room string          `json:"room,omitempty"` // The room of a broadcast message
+	Data json.RawMessage `json:"data,omitempty"` // The content
+}
+
+// Bind decodes the data of the message into the destination.
+func (m *WebSocketMessage) Bind(dest interface{}) error {
+	return json.Unmarshal(m.Data, dest)
+}
+
+// WebSocketHandler handles the messages of a type received from a connection.
+type WebSocketHandler func(conn *WebSocketConn, message *WebSocketMessage)
+
+// WebSocketPinger is implemented by a ServerWebSocket which can send ping
+// frames, the client answers them with pong frames.
+type WebSocketPinger interface {
+	Ping() error
+}
+
+// WebSocketReadTimeouter is implemented by a ServerWebSocket whose read
+// deadline is extended each time data is received, the pongs answering the
+// pings included.
+type WebSocketReadTimeouter interface {
+	SetReadTimeout(timeout time.Duration) error
+}
+
+// WebSocketHub keeps the websocket connections of the application, groups
+// them into named rooms and dispatches the received messages to the handler
+// for their type. Broadcasts go through the PubSub, so they reach the
+// connections on every instance when the PubSub is shared. For example:
+//
+//	var chat = revel.NewWebSocketHub("chat")
+//
+//	func init() {
+//		chat.Handle("join", func(conn *revel.WebSocketConn, message *revel.WebSocketMessage) {
+//			conn.Join(message.Room)
+//		})
+//		chat.Handle("say", func(conn *revel.WebSocketConn, message *revel.WebSocketMessage) {
+//			chat.Broadcast(message.Room, "said", message.Data)
+//		})
+//	}
+//
+//	func (c Chat) Socket(user string, ws revel.ServerWebSocket) revel.Result {
+//		chat.Serve(ws, user)
+//		return nil
+//	}
+//
+// Each connection has a send queue, a connection which does not keep up with
+// its messages is closed once the queue is full (or the messages are dropped
+// if DropWhenFull is set) so a slow client never blocks a broadcast. A
+// connection from which nothing, not even a pong, is received for the
+// PingInterval and the PongTimeout is closed.
+type WebSocketHub struct {
+	Name         string                             // The name, broadcasts are published on the topic "revel.websocket.<name>"
+	PubSub       PubSub                             // The PubSub for broadcasts, the DefaultPubSub if nil
+	QueueSize    int                                // The messages queued per connection, set via websocket.queue.size (default 64)
+	DropWhenFull bool                               // Drop the messages for a full queue instead of closing the connection, set via websocket.queue.drop
+	PingInterval time.Duration                      // The interval between pings, set via websocket.ping.interval (default 30s), negative for no pings
+	WriteTimeout time.Duration                      // The time allowed to write a message, set via websocket.write.timeout (default 10s)
+	PongTimeout  time.Duration                      // The time allowed for the pong after a ping interval with nothing received, set via websocket.pong.timeout (default 10s)
+	OnConnect    func(conn *WebSocketConn)          // Called when a connection is registered
+	OnClose      func(conn *WebSocketConn)          // Called when a connection is removed
+	handlers     map[string]WebSocketHandler        // The handlers by message type
+	conns        map[*WebSocketConn]bool            // The connections
+	rooms        map[string]map[*WebSocketConn]bool // The connections by room
+	mutex        sync.RWMutex
+	startOnce    sync.Once
+	startErr     error  // The error subscribing to the PubSub
+	unsubscribe  func() // Ends the subscription to the PubSub
+}
+
+// WebSocketConn is a connection registered with a WebSocketHub.
+type WebSocketConn struct {
+	ID        string // The id of the client, shared by its connections
+	hub       *WebSocketHub
+	socket    ServerWebSocket
+	send      chan string     // The send queue
+	rooms     map[string]bool // The rooms joined, guarded by the hub mutex
+	done      chan struct{}   // Closed when the connection is closed
+	closeOnce sync.Once
+}
+
+// A broadcast published through the PubSub.
+type webSocketDelivery struct {
+	Room    string          `json:"room,omitempty"` // The room, all the connections if empty
+	To      string          `json:"to,omitempty"`   // The connection id, instead of a room
+	Message json.RawMessage `json:"message"`        // The message sent to the connections
+}
+
+// NewWebSocketHub returns a hub with the name, the settings which are not
+// set are read from the configuration when the first connection is served.
+func NewWebSocketHub(name string) *WebSocketHub {
+	return &WebSocketHub{
+		Name:     name,
+		handlers: map[string]WebSocketHandler{},
+		conns:    map[*WebSocketConn]bool{},
+		rooms:    map[string]map[*WebSocketConn]bool{},
+	}
+}
+
+// Handle sets the handler for the messages of the type.
+func (h *WebSocketHub) Handle(messageType string, handler WebSocketHandler) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.handlers[messageType] = handler
+}
+
+// Serve registers the websocket with the hub and handles the messages
+// received from it, it returns when the connection is closed. The id
+// identifies the client (e.g. the user name) for SendTo, the connection joins
+// the rooms before any message is handled.
+func (h *WebSocketHub) Serve(ws ServerWebSocket, id string, rooms ...string) error {
+	if err := h.start(); err != nil {
+		return err
+	}
+	conn := &WebSocketConn{
+		ID:     id,
+		hub:    h,
+		socket: ws,
+		send:   make(chan string, h.QueueSize),
+		rooms:  map[string]bool{},
+		done:   make(chan struct{}),
+	}
+	h.mutex.Lock()
+	h.conns[conn] = true
+	h.mutex.Unlock()
+	for _, room := range rooms {
+		conn.Join(room)
+	}
+	if h.OnConnect != nil {
+		h.OnConnect(conn)
+	}
+
+	// A connection which stops answering the pings is closed by the read timeout
+	_, canPing := ws.(WebSocketPinger)
+	if timeouter, ok := ws.(WebSocketReadTimeouter); ok && canPing && h.PingInterval > 0 {
+		if err := timeouter.SetReadTimeout(h.PingInterval + h.PongTimeout); err != nil {
+			websocketLog.Debug("Serve: Failed to set the read timeout", "hub", h.Name, "id", id, "error", err)
+		}
+	}
+
+	go conn.writeLoop()
+	err := conn.readLoop()
+	conn.Close()
+	h.remove(conn)
+	if h.OnClose != nil {
+		h.OnClose(conn)
+	}
+	if err == io.EOF || err == ErrWebSocketClosed {
+		err = nil
+	}
+	return err
+}
+
+// Broadcast sends the message to the connections in the room, or to every
+// connection if the room is empty.
+func (h *WebSocketHub) Broadcast(room, messageType string, data interface{}) error {
+	message, err := encodeWebSocketMessage(messageType, room, data)
+	if err != nil {
+		return err
+	}
+	return h.publish(&webSocketDelivery{Room: room, Message: message})
+}
+
+// SendTo sends the message to the connections of the client with the id.
+func (h *WebSocketHub) SendTo(id, messageType string, data interface{}) error {
+	message, err := encodeWebSocketMessage(messageType, "", data)
+	if err != nil {
+		return err
+	}
+	return h.publish(&webSocketDelivery{To: id, Message: message})
+}
+
+// Connections returns the number of connections on this instance, in the
+// room if one is given.
+func (h *WebSocketHub) Connections(room ...string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if len(room) > 0 {
+		return len(h.rooms[room[0]])
+	}
+	return len(h.conns)
+}
+
+// Close closes the connections and ends the subscription to the PubSub.
+func (h *WebSocketHub) Close() {
+	h.mutex.RLock()
+	conns := make([]*WebSocketConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	unsubscribe := h.unsubscribe
+	h.mutex.RUnlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// Fills in the settings from the configuration and subscribes to the PubSub.
+func (h *WebSocketHub) start() error {
+	h.startOnce.Do(func() {
+		if h.QueueSize <= 0 {
+			h.QueueSize = Config.IntDefault("websocket.queue.size", 64)
+		}
+		h.DropWhenFull = h.DropWhenFull || Config.BoolDefault("websocket.queue.drop", false)
+		if h.PingInterval == 0 {
+			h.PingInterval = ConfigDuration("websocket.ping.interval", 30*time.Second)
+		}
+		if h.WriteTimeout == 0 {
+			h.WriteTimeout = ConfigDuration("websocket.write.timeout", 10*time.Second)
+		}
+		if h.PongTimeout == 0 {
+			h.PongTimeout = ConfigDuration("websocket.pong.timeout", 10*time.Second)
+		}
+		if h.PubSub == nil {
+			h.PubSub = DefaultPubSub
+		}
+
+		unsubscribe, err := h.PubSub.Subscribe(h.topic(), h.deliver)
+		h.mutex.Lock()
+		h.unsubscribe, h.startErr = unsubscribe, err
+		h.mutex.Unlock()
+		if err != nil {
+			websocketLog.Error("start: Failed to subscribe to broadcasts", "hub", h.Name, "error", err)
+		}
+	})
+	return h.startErr
+}
+
+// Returns the PubSub topic of the hub.
+func (h *WebSocketHub) topic() string {
+	return "revel.websocket." + h.Name
+}
+
+// Publishes the delivery to every instance.
+func (h *WebSocketHub) publish(delivery *webSocketDelivery) error {
+	if err := h.start(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return h.PubSub.Publish(h.topic(), payload)
+}
+
+// Queues a delivery received from the PubSub for the connections on this instance.
+func (h *WebSocketHub) deliver(payload []byte) {
+	delivery := &webSocketDelivery{}
+	if err := json.Unmarshal(payload, delivery); err != nil {
+		websocketLog.Error("deliver: Invalid broadcast", "hub", h.Name, "error", err)
+		return
+	}
+
+	h.mutex.RLock()
+	var targets []*WebSocketConn
+	switch {
+	case delivery.To != "":
+		for conn := range h.conns {
+			if conn.ID == delivery.To {
+				targets = append(targets, conn)
+			}
+		}
+	case delivery.Room != "":
+		for conn := range h.rooms[delivery.Room] {
+			targets = append(targets, conn)
+		}
+	default:
+		for conn := range h.conns {
+			targets = append(targets, conn)
+		}
+	}
+	h.mutex.RUnlock()
+
+	message := string(delivery.Message)
+	for _, conn := range targets {
+		_ = conn.enqueue(message)
+	}
+}
+
+// Removes the connection from the hub and its rooms.
+func (h *WebSocketHub) remove(conn *WebSocketConn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.conns, conn)
+	for room := range conn.rooms {
+		h.leave(conn, room)
+	}
+}
+
+// Removes the connection from the room, the caller holds the mutex.
+func (h *WebSocketHub) leave(conn *WebSocketConn, room string) {
+	delete(conn.rooms, room)
+	delete(h.rooms[room], conn)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Returns the handler for the message type.
+func (h *WebSocketHub) handler(messageType string) WebSocketHandler {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.handlers[messageType]
+}
+
+// Join adds the connection to the room.
+func (c *WebSocketConn) Join(room string) {
+	c.hub.mutex.Lock()
+	defer c.hub.mutex.Unlock()
+	if _, found := c.hub.conns[c]; !found {
+		return
+	}
+	if c.hub.rooms[room] == nil {
+		c.hub.rooms[room] = map[*WebSocketConn]bool{}
+	}
+	c.hub.rooms[room][c] = true
+	c.rooms[room] = true
+}
+
+// Leave removes the connection from the room.
+func (c *WebSocketConn) Leave(room string) {
+	c.hub.mutex.Lock()
+	defer c.hub.mutex.Unlock()
+	c.hub.leave(c, room)
+}
+
+// Rooms returns the rooms the connection has joined.
+func (c *WebSocketConn) Rooms() (rooms []string) {
+	c.hub.mutex.RLock()
+	defer c.hub.mutex.RUnlock()
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	return
+}
+
+// Send queues the message for this connection only.
+func (c *WebSocketConn) Send(messageType string, data interface{}) error {
+	message, err := encodeWebSocketMessage(messageType, "", data)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(string(message))
+}
+
+// Close closes the connection, Serve returns once it has been closed.
+func (c *WebSocketConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		// Closing the socket ends the read loop
+		if closer, ok := c.socket.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				websocketLog.Debug("Close: Error closing websocket", "hub", c.hub.Name, "id", c.ID, "error", err)
+			}
+		}
+	})
+}
+
+// Queues the message, if the queue is full the connection is closed (or the
+// message dropped).
+func (c *WebSocketConn) enqueue(message string) error {
+	select {
+	case <-c.done:
+		return ErrWebSocketClosed
+	default:
+	}
+	select {
+	case c.send <- message:
+		return nil
+	default:
+	}
+	if !c.hub.DropWhenFull {
+		websocketLog.Warn("enqueue: Closing slow websocket connection", "hub", c.hub.Name, "id", c.ID)
+		c.Close()
+	}
+	return ErrWebSocketQueueFull
+}
+
+// Dispatches the received messages until the socket is closed.
+func (c *WebSocketConn) readLoop() error {
+	for {
+		var text string
+		if err := c.socket.MessageReceive(&text); err != nil {
+			return err
+		}
+		message := &WebSocketMessage{}
+		if err := json.Unmarshal([]byte(text), message); err != nil || message.Type == "" {
+			websocketLog.Debug("readLoop: Ignoring invalid message", "hub", c.hub.Name, "id", c.ID, "error", err)
+			continue
+		}
+		handler := c.hub.handler(message.Type)
+		if handler == nil {
+			websocketLog.Debug("readLoop: No handler for message", "hub", c.hub.Name, "id", c.ID, "type", message.Type)
+			continue
+		}
+		handler(c, message)
+	}
+}
+
+// Writes the queued messages and the pings until the connection is closed,
+// this is the only goroutine writing to the socket.
+func (c *WebSocketConn) writeLoop() {
+	var ping <-chan time.Time
+	pinger, canPing := c.socket.(WebSocketPinger)
+	if canPing && c.hub.PingInterval > 0 {
+		ticker := time.NewTicker(c.hub.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	deadliner, hasDeadline := c.socket.(interface{ SetWriteDeadline(time.Time) error })
+
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case message := <-c.send:
+			if hasDeadline && c.hub.WriteTimeout > 0 {
+				_ = deadliner.SetWriteDeadline(time.Now().Add(c.hub.WriteTimeout))
+			}
+			err = c.socket.MessageSend(message)
+		case <-ping:
+			if hasDeadline && c.hub.WriteTimeout > 0 {
+				_ = deadliner.SetWriteDeadline(time.Now().Add(c.hub.WriteTimeout))
+			}
+			err = pinger.Ping()
+		}
+		if err != nil {
+			websocketLog.Debug("writeLoop: Error writing to websocket", "hub", c.hub.Name, "id", c.ID, "error", err)
+			c.Close()
+			return
+		}
+	}
+}
+
+// Encodes the message sent to the clients.
+func encodeWebSocketMessage(messageType, room string, data interface{}) (json.RawMessage, error) {
+	var raw json.RawMessage
+	switch value := data.(type) {
+	case nil:
+	case json.RawMessage:
+		raw = value
+	default:
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(&WebSocketMessage{Type: messageType, Room: room, Data: raw})
+}
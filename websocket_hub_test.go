@@ -0,0 +1,232 @@
+// Copyright (c) 2012-2016 The Revel Framework Authors, All rights reserved.
+// Revel Framework source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package revel
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// A websocket exchanging the messages through channels.
+type testWebSocket struct {
+	ServerResponse
+	in        chan string
+	out       chan string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newTestWebSocket(outSize int) *testWebSocket {
+	return &testWebSocket{in: make(chan string), out: make(chan string, outSize), closed: make(chan struct{})}
+}
+
+func (ws *testWebSocket) MessageSendJSON(v interface{}) error    { return nil }
+func (ws *testWebSocket) MessageReceiveJSON(v interface{}) error { return nil }
+
+func (ws *testWebSocket) MessageSend(v interface{}) error {
+	select {
+	case ws.out <- v.(string):
+		return nil
+	case <-ws.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (ws *testWebSocket) MessageReceive(v interface{}) error {
+	select {
+	case message := <-ws.in:
+		*v.(*string) = message
+		return nil
+	case <-ws.closed:
+		return io.EOF
+	}
+}
+
+func (ws *testWebSocket) Close() error {
+	ws.closeOnce.Do(func() { close(ws.closed) })
+	return nil
+}
+
+// Returns the next message sent to the websocket.
+func (ws *testWebSocket) next(t *testing.T) string {
+	select {
+	case message := <-ws.out:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message sent to the websocket")
+	}
+	return ""
+}
+
+func newTestHub(name string, pubsub PubSub) *WebSocketHub {
+	hub := NewWebSocketHub(name)
+	hub.PubSub = pubsub
+	hub.QueueSize = 4
+	hub.PingInterval = -1
+	hub.WriteTimeout = time.Second
+	hub.Handle("join", func(conn *WebSocketConn, message *WebSocketMessage) {
+		conn.Join(message.Room)
+		_ = conn.Send("joined", message.Room)
+	})
+	hub.Handle("say", func(conn *WebSocketConn, message *WebSocketMessage) {
+		var text string
+		if err := message.Bind(&text); err == nil {
+			_ = hub.Broadcast(message.Room, "said", conn.ID+": "+text)
+		}
+	})
+	return hub
+}
+
+func TestWebSocketHub(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	// Two instances sharing the pubsub
+	pubsub := NewLocalPubSub()
+	hub1, hub2 := newTestHub("chat", pubsub), newTestHub("chat", pubsub)
+	alice, bob, carol := newTestWebSocket(4), newTestWebSocket(4), newTestWebSocket(4)
+	var served sync.WaitGroup
+	for _, serve := range []func(){
+		func() { a.Nil(hub1.Serve(alice, "alice")) },
+		func() { a.Nil(hub2.Serve(bob, "bob", "lobby")) },
+		func() { a.Nil(hub2.Serve(carol, "carol")) },
+	} {
+		served.Add(1)
+		go func(serve func()) {
+			defer served.Done()
+			serve()
+		}(serve)
+	}
+
+	alice.in <- `{"type":"join","room":"lobby"}`
+	a.Equal(`{"type":"joined","data":"lobby"}`, alice.next(t))
+	a.Equal(1, hub1.Connections("lobby"))
+
+	alice.in <- `{"type":"say","room":"lobby","data":"hello"}`
+	a.Equal(`{"type":"said","room":"lobby","data":"alice: hello"}`, alice.next(t))
+	a.Equal(`{"type":"said","room":"lobby","data":"alice: hello"}`, bob.next(t))
+
+	a.Nil(hub1.SendTo("carol", "private", map[string]int{"count": 1}))
+	a.Equal(`{"type":"private","data":{"count":1}}`, carol.next(t))
+
+	a.Nil(hub2.Broadcast("", "notice", "all"))
+	a.Equal(`{"type":"notice","data":"all"}`, alice.next(t))
+	a.Equal(`{"type":"notice","data":"all"}`, bob.next(t))
+	a.Equal(`{"type":"notice","data":"all"}`, carol.next(t))
+
+	// Unknown and invalid messages are ignored
+	bob.in <- `{"type":"unknown"}`
+	bob.in <- `not json`
+	select {
+	case message := <-bob.out:
+		t.Errorf("Unexpected message %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	bob.Close()
+	for hub2.Connections("lobby") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hub1.Close()
+	hub2.Close()
+	served.Wait()
+	a.Equal(0, hub1.Connections())
+	a.Equal(0, hub2.Connections())
+}
+
+func TestWebSocketHubSlowConnection(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	hub := newTestHub("slow", NewLocalPubSub())
+	hub.QueueSize = 1
+	// The websocket accepts no messages, so the send queue fills
+	slow := newTestWebSocket(0)
+	done := make(chan error)
+	go func() { done <- hub.Serve(slow, "slow") }()
+	for hub.Connections() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// One message is being written and one is queued, the next closes the connection
+	for i := 0; i < 3; i++ {
+		a.Nil(hub.Broadcast("", "tick", i), "A broadcast is never blocked by a slow connection")
+	}
+
+	var err error
+	select {
+	case err = <-done:
+		a.Nil(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The slow connection was not closed")
+	}
+	a.Equal(0, hub.Connections())
+}
+
+func TestWebSocketHubPongTimeout(t *testing.T) {
+	a := assert.New(t)
+	startFakeBookingApp()
+
+	hub := newTestHub("keepalive", NewLocalPubSub())
+	hub.PingInterval = 20 * time.Millisecond
+	hub.PongTimeout = 50 * time.Millisecond
+	served := make(chan error, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveGoWebSocket(w, r, func(ws *websocket.Conn, reader *goWebSocketReader) {
+			served <- hub.Serve(&GoWebSocket{Conn: ws, reader: reader}, r.URL.Query().Get("id"))
+		})
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// A client reading from the connection answers the pings with pongs
+	alive, err := websocket.Dial(url+"?id=alive", "", server.URL)
+	a.Nil(err)
+	defer alive.Close()
+	go func() {
+		var message string
+		for websocket.Message.Receive(alive, &message) == nil {
+		}
+	}()
+
+	// A client which never reads never answers the pings
+	dead, err := websocket.Dial(url+"?id=dead", "", server.URL)
+	a.Nil(err)
+	defer dead.Close()
+
+	select {
+	case err = <-served:
+		a.NotNil(err, "Expected the read timeout")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The connection missing the pongs was not closed")
+	}
+	time.Sleep(200 * time.Millisecond)
+	a.Equal(1, hub.Connections(), "Expected the connection answering the pings to stay open")
+	hub.Close()
+	<-served
+}
+
+func TestLocalPubSub(t *testing.T) {
+	a := assert.New(t)
+	pubsub := NewLocalPubSub()
+	var received []string
+	unsubscribe, err := pubsub.Subscribe("topic", func(message []byte) {
+		received = append(received, string(message))
+	})
+	a.Nil(err)
+	a.Nil(pubsub.Publish("topic", []byte("one")))
+	a.Nil(pubsub.Publish("other", []byte("two")))
+	unsubscribe()
+	a.Nil(pubsub.Publish("topic", []byte("three")))
+	a.Equal([]string{"one"}, received)
+}